@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API is the LAN control interface for the watchdog. With several routers,
+// requests pick one with ?router=name.
+type API struct {
+	monitors []*Monitor
+	history  *HistoryStore
+	token    string
+}
+
+func NewAPI(history *HistoryStore, token string, monitors ...*Monitor) *API {
+	return &API{monitors: monitors, history: history, token: token}
+}
+
+// Handler returns the API routes, all behind bearer token authentication.
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", a.handleStatus)
+	mux.HandleFunc("GET /history", a.handleHistory)
+	mux.HandleFunc("POST /reboot", a.handleReboot)
+	mux.HandleFunc("POST /pause", a.handlePause)
+	return a.authenticate(mux)
+}
+
+func (a *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || a.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vn007go"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// monitor finds the router named by ?router=, which may be left out when
+// only one router is monitored. It writes the error response itself.
+func (a *API) monitor(w http.ResponseWriter, r *http.Request) (*Monitor, bool) {
+	name := r.URL.Query().Get("router")
+	if name == "" && len(a.monitors) == 1 {
+		return a.monitors[0], true
+	}
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "several routers monitored, choose one with ?router=")
+		return nil, false
+	}
+	for _, m := range a.monitors {
+		if m.Name() == name {
+			return m, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "unknown router "+name)
+	return nil, false
+}
+
+// handleStatus returns the status of one router, or of every router keyed
+// by name when several are monitored and none is chosen.
+func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if len(a.monitors) > 1 && r.URL.Query().Get("router") == "" {
+		all := map[string]MonitorStatus{}
+		for _, m := range a.monitors {
+			all[m.Name()] = m.Status()
+		}
+		writeJSON(w, http.StatusOK, all)
+		return
+	}
+	m, ok := a.monitor(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, m.Status())
+}
+
+// handleHistory returns the recorded events, optionally limited with
+// ?since=24h and ?router=name.
+func (a *API) handleHistory(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid since duration")
+			return
+		}
+		since = time.Now().Add(-d)
+	}
+
+	entries, err := a.history.Entries(since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if router := r.URL.Query().Get("router"); router != "" {
+		entries = filterRouter(entries, router)
+	}
+	if entries == nil {
+		entries = []historyEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (a *API) handleReboot(w http.ResponseWriter, r *http.Request) {
+	m, ok := a.monitor(w, r)
+	if !ok {
+		return
+	}
+	m.RequestReboot()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "reboot requested"})
+}
+
+// handlePause accepts an optional {"paused": bool} body; an empty body
+// pauses.
+func (a *API) handlePause(w http.ResponseWriter, r *http.Request) {
+	m, ok := a.monitor(w, r)
+	if !ok {
+		return
+	}
+	req := struct {
+		Paused *bool `json:"paused"`
+	}{}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	paused := true
+	if req.Paused != nil {
+		paused = *req.Paused
+	}
+	m.Pause(paused)
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+const testToken = "s3cret"
+
+func newTestAPI(t *testing.T) (*API, *Monitor, *vn007test.Router, *fakeClock) {
+	t.Helper()
+	m, router, clock, _ := newTestMonitor(t)
+	history := newTestHistory(t)
+	m.AddSink(history)
+	return NewAPI(history, testToken, m), m, router, clock
+}
+
+func doRequest(t *testing.T, h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPI_RequiresToken(t *testing.T) {
+	api, _, _, _ := newTestAPI(t)
+	h := api.Handler()
+
+	for _, token := range []string{"", "wrong"} {
+		rec := doRequest(t, h, http.MethodGet, "/status", token, "")
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, rec.Code)
+		}
+	}
+	if rec := doRequest(t, h, http.MethodGet, "/status", testToken, ""); rec.Code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestAPI_Status(t *testing.T) {
+	api, m, _, clock := newTestAPI(t)
+	step(t, m, clock)
+
+	rec := doRequest(t, api.Handler(), http.MethodGet, "/status", testToken, "")
+	var status MonitorStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	if !strings.Contains(rec.Body.String(), `"state":"Healthy"`) {
+		t.Errorf("status body = %s, want state Healthy", rec.Body)
+	}
+	if status.Sample.Freq5G != "627264" || status.Sample.Uptime != 600 {
+		t.Errorf("sample = %+v", status.Sample)
+	}
+}
+
+func TestAPI_RebootAndHistory(t *testing.T) {
+	api, m, _, clock := newTestAPI(t)
+	h := api.Handler()
+	step(t, m, clock)
+
+	if rec := doRequest(t, h, http.MethodPost, "/reboot", testToken, ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /reboot = %d, want 202", rec.Code)
+	}
+	step(t, m, clock)
+	if m.State() != StateCoolingDown {
+		t.Fatalf("state = %s after manual reboot, want CoolingDown", m.State())
+	}
+
+	rec := doRequest(t, h, http.MethodGet, "/history", testToken, "")
+	var entries []historyEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	var reboots int
+	for _, e := range entries {
+		if e.Kind == EventReboot {
+			reboots++
+			if e.Cause != "manual" {
+				t.Errorf("reboot cause = %q, want manual", e.Cause)
+			}
+		}
+	}
+	if reboots != 1 || len(entries) != 1 {
+		t.Errorf("history = %s, want a single reboot", rec.Body)
+	}
+
+	rec = doRequest(t, h, http.MethodGet, "/history?since=1h", testToken, "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+		t.Errorf("history since 1h = %d %s, want empty list (fake clock is in 2024)", rec.Code, rec.Body)
+	}
+
+	if rec := doRequest(t, h, http.MethodGet, "/reboot", testToken, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /reboot = %d, want 405", rec.Code)
+	}
+}
+
+func TestAPI_PauseSuppressesAutoReboot(t *testing.T) {
+	api, m, router, clock := newTestAPI(t)
+	h := api.Handler()
+
+	if rec := doRequest(t, h, http.MethodPost, "/pause", testToken, ""); rec.Code != http.StatusOK || !m.Paused() {
+		t.Fatalf("POST /pause = %d, paused = %v", rec.Code, m.Paused())
+	}
+
+	step(t, m, clock)
+	router.Drop5G()
+	step(t, m, clock)
+	router.Advance(recoverSecs+1, 0, 0)
+	step(t, m, clock)
+	if router.Reboots() != 0 {
+		t.Fatalf("rebooted while paused")
+	}
+
+	if rec := doRequest(t, h, http.MethodPost, "/pause", testToken, `{"paused":false}`); rec.Code != http.StatusOK || m.Paused() {
+		t.Fatalf("resume = %d, paused = %v", rec.Code, m.Paused())
+	}
+	step(t, m, clock)
+	if router.Reboots() != 1 {
+		t.Errorf("reboots after resume = %d, want 1", router.Reboots())
+	}
+
+	if rec := doRequest(t, h, http.MethodPost, "/pause", testToken, `{`); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad body = %d, want 400", rec.Code)
+	}
+}
+
+func TestAPI_SeveralRouters(t *testing.T) {
+	home, _, clock, _ := newTestMonitor(t)
+	home.SetName("home")
+	officeRouter := vn007test.NewRouter()
+	t.Cleanup(officeRouter.Close)
+	office := NewMonitor(officeRouter.Client(), DefaultPolicy, clock)
+	office.SetName("office")
+	h := NewAPI(newTestHistory(t), testToken, home, office).Handler()
+
+	rec := doRequest(t, h, http.MethodGet, "/status", testToken, "")
+	var all map[string]MonitorStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil || len(all) != 2 {
+		t.Fatalf("GET /status = %s, want both routers", rec.Body)
+	}
+
+	if rec := doRequest(t, h, http.MethodPost, "/pause", testToken, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /pause without router = %d, want 400", rec.Code)
+	}
+	if rec := doRequest(t, h, http.MethodPost, "/pause?router=garage", testToken, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /pause for unknown router = %d, want 404", rec.Code)
+	}
+	if rec := doRequest(t, h, http.MethodPost, "/pause?router=office", testToken, ""); rec.Code != http.StatusOK {
+		t.Errorf("POST /pause?router=office = %d, want 200", rec.Code)
+	}
+	if home.Paused() || !office.Paused() {
+		t.Errorf("paused = %v, %v, want only office", home.Paused(), office.Paused())
+	}
+}
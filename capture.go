@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+// recordTo appends every exchange of the monitors' clients to the JSONL
+// file at path, with passwords and session IDs redacted. The caller closes
+// the returned file when monitoring has stopped.
+func recordTo(path string, monitors []*Monitor) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening record file: %v", err)
+	}
+	for _, m := range monitors {
+		m.client.HTTPClient.Transport = vn007.NewRecorder(f, m.Name(), m.client.HTTPClient.Transport)
+	}
+	return f, nil
+}
+
+// replayFrom answers the monitors' requests from a capture instead of the
+// routers. Each monitor is served the exchanges recorded under its name; a
+// single monitor falls back to the whole capture, so a recording from a
+// named profile replays with a plain .env. The returned channel is closed
+// once every monitor has run out of replies.
+func replayFrom(path string, monitors []*Monitor) (<-chan struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening replay file: %v", err)
+	}
+	defer f.Close()
+	exchanges, err := vn007.ReadCapture(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var replayers []*vn007.Replayer
+	for _, m := range monitors {
+		var mine []vn007.Exchange
+		for _, ex := range exchanges {
+			if ex.Router == m.Name() {
+				mine = append(mine, ex)
+			}
+		}
+		if len(mine) == 0 && len(monitors) == 1 {
+			mine = exchanges
+		}
+		if len(mine) == 0 {
+			return nil, fmt.Errorf("%s: nothing recorded for router %q", path, m.Name())
+		}
+		replayer := vn007.NewReplayer(mine)
+		m.client.HTTPClient.Transport = replayer
+		replayers = append(replayers, replayer)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for _, r := range replayers {
+			<-r.Done()
+		}
+		close(done)
+	}()
+	return done, nil
+}
+
+// replayStores points the history and usage files at a scratch directory,
+// so a replay neither adds its outages to the real history nor counts its
+// traffic against the real budgets. Paths the user set explicitly are
+// kept. The caller removes the returned directory.
+func replayStores(explicit map[string]bool, historyPath, usagePath *string) (string, error) {
+	dir, err := os.MkdirTemp("", "vn007go-replay-")
+	if err != nil {
+		return "", fmt.Errorf("error creating replay directory: %v", err)
+	}
+	if !explicit["history"] {
+		*historyPath = filepath.Join(dir, "history.jsonl")
+	}
+	if !explicit["usage"] {
+		*usagePath = filepath.Join(dir, "usage.json")
+	}
+	return dir, nil
+}
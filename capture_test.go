@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+
+	m, router, clock, sink := newTestMonitor(t)
+	f, err := recordTo(path, []*Monitor{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(1, 0, 0)
+	step(t, m, clock)
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock)
+	f.Close()
+	if router.Reboots() != 1 {
+		t.Fatalf("reboots = %d, want 1 while recording", router.Reboots())
+	}
+
+	replayed, _, replayClock, replaySink := newTestMonitor(t)
+	done, err := replayFrom(path, []*Monitor{replayed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		step(t, replayed, replayClock)
+	}
+	if replayed.State() != StateCoolingDown {
+		t.Errorf("replayed state = %s, want CoolingDown after the recorded reboot", replayed.State())
+	}
+	if len(replaySink.samples) != len(sink.samples) || replaySink.count(EventReboot) != 1 {
+		t.Errorf("replay gave %d samples and %d reboots, want %d and 1", len(replaySink.samples), replaySink.count(EventReboot), len(sink.samples))
+	}
+
+	replayClock.Sleep(context.Background(), DefaultPolicy.RebootSleep)
+	step(t, replayed, replayClock)
+	select {
+	case <-done:
+	default:
+		t.Error("replay not done after the capture ran out")
+	}
+}
+
+func TestReplayFrom_UnknownRouter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	os.WriteFile(path, []byte(`{"router":"home","cmd":133,"status":200,"response":{"success":true}}`+"\n"), 0o600)
+
+	home, _, _, _ := newTestMonitor(t)
+	home.SetName("home")
+	office, _, _, _ := newTestMonitor(t)
+	office.SetName("office")
+	if _, err := replayFrom(path, []*Monitor{home, office}); err == nil {
+		t.Error("replayFrom accepted a router missing from the capture")
+	}
+}
+
+func TestReplayStores(t *testing.T) {
+	history, usage := "history.jsonl", "usage.json"
+	dir, err := replayStores(map[string]bool{"usage": true}, &history, &usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if history != filepath.Join(dir, "history.jsonl") || usage != "usage.json" {
+		t.Errorf("history = %q, usage = %q; want only the default history moved to %s", history, usage, dir)
+	}
+}
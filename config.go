@@ -0,0 +1,581 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+// Bands are the RSRQ thresholds for the signal bars in the header: at or
+// below Poor shows one bar, at or below Fair two, at or below Good three,
+// anything better four.
+type Bands struct {
+	Poor int
+	Fair int
+	Good int
+}
+
+func (b Bands) String() string {
+	return fmt.Sprintf("%d,%d,%d", b.Poor, b.Fair, b.Good)
+}
+
+// Set parses "poor,fair,good", e.g. "-15,-10,-5".
+func (b *Bands) Set(s string) error {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return fmt.Errorf("want three comma-separated values, got %q", s)
+	}
+	var v [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return fmt.Errorf("invalid band %q", part)
+		}
+		v[i] = n
+	}
+	*b = Bands{Poor: v[0], Fair: v[1], Good: v[2]}
+	return nil
+}
+
+// Bars returns how many of the four signal bars v earns.
+func (b Bands) Bars(v int) int {
+	switch {
+	case v <= b.Poor:
+		return 1
+	case v <= b.Fair:
+		return 2
+	case v <= b.Good:
+		return 3
+	}
+	return 4
+}
+
+// Policy holds the tunable thresholds of the watchdog.
+type Policy struct {
+	RecoverTime  time.Duration // how long 5G may be gone before rebooting
+	RecoverBytes int           // 4G bytes allowed while waiting for 5G before rebooting
+	RebootWait   time.Duration // uptime below which the header flags a fresh boot
+	RebootSleep  time.Duration // wait after a reboot before polling again
+	MaxRetries   int           // attempts per router request
+	BaseDelay    time.Duration // poll interval and first retry backoff
+	MaxDelay     time.Duration // retry backoff cap
+	RSRQBands    Bands         // 4G signal bars
+	RSRQ5GBands  Bands         // 5G signal bars
+
+	MaxRebootsHour   int           // reboots allowed per hour, 0 for no cap
+	MaxRebootsDay    int           // reboots allowed per day, 0 for no cap
+	RebootBackoff    time.Duration // wait after a reboot that did not bring 5G back, doubled for each further one
+	MaxRebootBackoff time.Duration // reboot backoff cap
+	RecoverySteps    Ladder        // what to try, in order, once the recovery window is over
+
+	Budget4GDay     int          // 4G bytes allowed per day, 0 for no budget
+	Budget4GMonth   int          // 4G bytes allowed per month, 0 for no budget
+	BudgetThreshold int          // percentage of a budget at which BudgetAction is taken
+	BudgetAction    BudgetAction // nsa or data
+
+	BandLockHold time.Duration // how long to lock NR to the last good band after 5G drops, 0 for never
+
+	// Experimental allows the modem controls whose command numbers have
+	// not been confirmed against a capture of a real router: the reattach
+	// and data recovery steps, the budget action, band locks and network
+	// mode changes.
+	Experimental bool
+
+	RebootSchedule Schedule // planned reboots, off by default
+	QuietWindows   Windows  // times without automatic reboots
+}
+
+var DefaultPolicy = Policy{
+	RecoverTime:  5 * time.Second,
+	RecoverBytes: 10000000, // 10MB
+	RebootWait:   4 * time.Minute,
+	RebootSleep:  60 * time.Second,
+	MaxRetries:   5,
+	BaseDelay:    1 * time.Second,
+	MaxDelay:     32 * time.Second,
+	RSRQBands:    Bands{Poor: -16, Fair: -10, Good: -5},
+	RSRQ5GBands:  Bands{Poor: -15, Fair: -9, Good: -5},
+
+	MaxRebootsHour:   3,
+	MaxRebootsDay:    12,
+	RebootBackoff:    5 * time.Minute,
+	MaxRebootBackoff: 2 * time.Hour,
+	RecoverySteps:    Ladder{{Action: actionReboot}},
+
+	BudgetThreshold: 90,
+	BudgetAction:    budgetNSA,
+}
+
+// Retry returns the client retry policy.
+func (p Policy) Retry() vn007.RetryPolicy {
+	return vn007.RetryPolicy{
+		MaxRetries: p.MaxRetries,
+		BaseDelay:  p.BaseDelay,
+		MaxDelay:   p.MaxDelay,
+	}
+}
+
+// rebootBackoff returns the wait before the next recovery reboot after n
+// that did not bring 5G back.
+func (p Policy) rebootBackoff(n int) time.Duration {
+	backoff := p.RebootBackoff
+	for i := 1; i < n && backoff < p.MaxRebootBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxRebootBackoff {
+		backoff = p.MaxRebootBackoff
+	}
+	return backoff
+}
+
+// policySetting ties one policy field to its env var, config key and flag.
+type policySetting struct {
+	env   string
+	flag  string
+	usage string
+	value flag.Value
+}
+
+// key is the setting's name in the config file.
+func (s policySetting) key() string {
+	return strings.ReplaceAll(s.flag, "-", "_")
+}
+
+func (p *Policy) settings() []policySetting {
+	return []policySetting{
+		{"RECOVER_TIME", "recover-time", "how long 5G may be gone before rebooting", (*durationValue)(&p.RecoverTime)},
+		{"RECOVER_BYTES", "recover-bytes", "4G bytes allowed while waiting for 5G before rebooting", (*intValue)(&p.RecoverBytes)},
+		{"REBOOT_WAIT", "reboot-wait", "uptime below which the header flags a fresh boot", (*durationValue)(&p.RebootWait)},
+		{"REBOOT_SLEEP", "reboot-sleep", "wait after a reboot before polling again", (*durationValue)(&p.RebootSleep)},
+		{"MAX_RETRIES", "max-retries", "attempts per router request", (*intValue)(&p.MaxRetries)},
+		{"BASE_DELAY", "base-delay", "poll interval and first retry backoff", (*durationValue)(&p.BaseDelay)},
+		{"MAX_DELAY", "max-delay", "retry backoff cap", (*durationValue)(&p.MaxDelay)},
+		{"RSRQ_BANDS", "rsrq-bands", "4G RSRQ thresholds for 1, 2 and 3 signal bars", &p.RSRQBands},
+		{"RSRQ_5G_BANDS", "rsrq-5g-bands", "5G RSRQ thresholds for 1, 2 and 3 signal bars", &p.RSRQ5GBands},
+		{"MAX_REBOOTS_HOUR", "max-reboots-hour", "reboots allowed per hour, 0 for no cap", (*intValue)(&p.MaxRebootsHour)},
+		{"MAX_REBOOTS_DAY", "max-reboots-day", "reboots allowed per day, 0 for no cap", (*intValue)(&p.MaxRebootsDay)},
+		{"REBOOT_BACKOFF", "reboot-backoff", "wait after a reboot that did not bring 5G back, doubled for each further one", (*durationValue)(&p.RebootBackoff)},
+		{"MAX_REBOOT_BACKOFF", "max-reboot-backoff", "reboot backoff cap", (*durationValue)(&p.MaxRebootBackoff)},
+		{"RECOVERY_STEPS", "recovery-steps", "what to try once 5G stays down, e.g. 'reattach:20s,data:30s,reboot'", &p.RecoverySteps},
+		{"BUDGET_4G_DAY", "budget-4g-day", "4G bytes allowed per day, 0 for no budget", (*intValue)(&p.Budget4GDay)},
+		{"BUDGET_4G_MONTH", "budget-4g-month", "4G bytes allowed per month, 0 for no budget", (*intValue)(&p.Budget4GMonth)},
+		{"BUDGET_THRESHOLD", "budget-threshold", "percentage of a 4G budget at which budget-action is taken", (*intValue)(&p.BudgetThreshold)},
+		{"BUDGET_ACTION", "budget-action", "what to do near a 4G budget: nsa forces 5G NSA only, data switches mobile data off", &p.BudgetAction},
+		{"NR_BAND_LOCK", "nr-band-lock", "how long to lock to the last good NR band after 5G drops, 0 for never", (*durationValue)(&p.BandLockHold)},
+		{"EXPERIMENTAL", "experimental", "allow modem controls not yet confirmed on a real router: reattach and data steps, budgets, band locks and network mode", (*switchValue)(&p.Experimental)},
+		{"REBOOT_SCHEDULE", "reboot-schedule", "cron expression for planned reboots, e.g. '0 4 * * *'", &p.RebootSchedule},
+		{"QUIET_WINDOWS", "quiet-windows", "times without automatic reboots, e.g. 'mon-fri 09:00-17:00; 22:00-06:00'", &p.QuietWindows},
+	}
+}
+
+// LoadEnv overrides policy fields from the environment.
+func (p *Policy) LoadEnv(getenv func(string) string) error {
+	var errs []error
+	for _, s := range p.settings() {
+		v := getenv(s.env)
+		if v == "" {
+			continue
+		}
+		if err := s.value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", s.env, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadMap overrides policy fields from a config file section keyed by
+// snake_case setting names, e.g. recover_time.
+func (p *Policy) LoadMap(m map[string]string) error {
+	byKey := map[string]policySetting{}
+	for _, s := range p.settings() {
+		byKey[s.key()] = s
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		s, ok := byKey[k]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown setting %q", k))
+			continue
+		}
+		if err := s.value.Set(m[k]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", k, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PolicyFlags holds policy values given on the command line. They are
+// collected at parse time and applied last, after the config file and the
+// environment.
+type PolicyFlags map[string]string
+
+// RegisterPolicyFlags adds a flag for every policy setting to fs.
+func RegisterPolicyFlags(fs *flag.FlagSet) PolicyFlags {
+	flags := PolicyFlags{}
+	defaults := DefaultPolicy
+	for _, s := range defaults.settings() {
+		name := s.flag
+		usage := fmt.Sprintf("%s (env %s, default %s)", s.usage, s.env, s.value)
+		set := func(v string) error {
+			scratch := DefaultPolicy
+			for _, ss := range scratch.settings() {
+				if ss.flag == name {
+					if err := ss.value.Set(v); err != nil {
+						return err
+					}
+				}
+			}
+			flags[name] = v
+			return nil
+		}
+		if _, ok := s.value.(*switchValue); ok {
+			fs.BoolFunc(name, usage, set)
+		} else {
+			fs.Func(name, usage, set)
+		}
+	}
+	return flags
+}
+
+// LoadFlags applies values collected by RegisterPolicyFlags.
+func (p *Policy) LoadFlags(flags PolicyFlags) error {
+	var errs []error
+	for _, s := range p.settings() {
+		if v, ok := flags[s.flag]; ok {
+			if err := s.value.Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("--%s: %v", s.flag, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every setting that is out of range.
+func (p Policy) Validate() error {
+	var errs []error
+	if p.RecoverTime <= 0 {
+		errs = append(errs, fmt.Errorf("recover-time must be positive"))
+	}
+	if p.RecoverBytes <= 0 {
+		errs = append(errs, fmt.Errorf("recover-bytes must be positive"))
+	}
+	if p.RebootWait < 0 {
+		errs = append(errs, fmt.Errorf("reboot-wait must not be negative"))
+	}
+	if p.RebootSleep <= 0 {
+		errs = append(errs, fmt.Errorf("reboot-sleep must be positive"))
+	}
+	if p.MaxRetries < 1 {
+		errs = append(errs, fmt.Errorf("max-retries must be at least 1"))
+	}
+	if p.BaseDelay <= 0 {
+		errs = append(errs, fmt.Errorf("base-delay must be positive"))
+	}
+	if p.MaxDelay < p.BaseDelay {
+		errs = append(errs, fmt.Errorf("max-delay must not be below base-delay"))
+	}
+	if b := p.RSRQBands; !(b.Poor < b.Fair && b.Fair < b.Good) {
+		errs = append(errs, fmt.Errorf("rsrq-bands must be increasing, got %s", b))
+	}
+	if b := p.RSRQ5GBands; !(b.Poor < b.Fair && b.Fair < b.Good) {
+		errs = append(errs, fmt.Errorf("rsrq-5g-bands must be increasing, got %s", b))
+	}
+	if p.MaxRebootsHour < 0 || p.MaxRebootsDay < 0 {
+		errs = append(errs, fmt.Errorf("max-reboots-hour and max-reboots-day must not be negative"))
+	}
+	if p.RebootBackoff < 0 {
+		errs = append(errs, fmt.Errorf("reboot-backoff must not be negative"))
+	}
+	if p.MaxRebootBackoff < p.RebootBackoff {
+		errs = append(errs, fmt.Errorf("max-reboot-backoff must not be below reboot-backoff"))
+	}
+	if p.Budget4GDay < 0 || p.Budget4GMonth < 0 {
+		errs = append(errs, fmt.Errorf("budget-4g-day and budget-4g-month must not be negative"))
+	}
+	if p.BandLockHold < 0 {
+		errs = append(errs, fmt.Errorf("nr-band-lock must not be negative"))
+	}
+	if p.BudgetThreshold < 1 || p.BudgetThreshold > 100 {
+		errs = append(errs, fmt.Errorf("budget-threshold must be between 1 and 100"))
+	}
+	if !p.Experimental {
+		for _, step := range p.RecoverySteps {
+			if step.Action != actionReboot {
+				errs = append(errs, fmt.Errorf("recovery step %s needs experimental", step.Action))
+			}
+		}
+		if p.Budget4GDay > 0 || p.Budget4GMonth > 0 {
+			errs = append(errs, fmt.Errorf("budget-4g-day and budget-4g-month need experimental"))
+		}
+		if p.BandLockHold > 0 {
+			errs = append(errs, fmt.Errorf("nr-band-lock needs experimental"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Rows lists the settings for display, in a fixed order.
+func (p *Policy) Rows() [][2]string {
+	var rows [][2]string
+	for _, s := range p.settings() {
+		rows = append(rows, [2]string{s.flag, s.value.String()})
+	}
+	return rows
+}
+
+type durationValue time.Duration
+
+func (d *durationValue) String() string { return time.Duration(*d).String() }
+
+func (d *durationValue) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = durationValue(v)
+	return nil
+}
+
+type switchValue bool
+
+func (b *switchValue) String() string { return strconv.FormatBool(bool(*b)) }
+
+func (b *switchValue) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid switch %q, want true or false", s)
+	}
+	*b = switchValue(v)
+	return nil
+}
+
+type intValue int
+
+func (i *intValue) String() string { return strconv.Itoa(int(*i)) }
+
+func (i *intValue) Set(s string) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*i = intValue(v)
+	return nil
+}
+
+// Profile is one router in the config file. Password is hashed the way
+// the web UI does it; PasswordHash, if also set, wins.
+type Profile struct {
+	IP           string            `yaml:"ip"`
+	User         string            `yaml:"user"`
+	Password     string            `yaml:"password"`
+	PasswordHash string            `yaml:"password_hash"`
+	Policy       map[string]string `yaml:"policy"`
+}
+
+// Config is the config file, by default ~/.config/vn007go/config.yaml.
+type Config struct {
+	DefaultProfile string             `yaml:"default_profile"`
+	APIToken       string             `yaml:"api_token"`
+	Debug          bool               `yaml:"debug"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// Settings is the resolved configuration for one run.
+type Settings struct {
+	Profile      string
+	IP           string
+	User         string
+	PasswordHash string
+	APIToken     string
+	Debug        bool
+	Policy       Policy
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "config.yaml"
+	}
+	return filepath.Join(dir, "vn007go", "config.yaml")
+}
+
+// LoadConfig reads the config file at path. A missing file yields an empty
+// config so .env-only setups keep working.
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening config: %v", err)
+	}
+	defer f.Close()
+
+	var cfg Config
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// selectProfiles returns the profiles to monitor, in order. names is a
+// comma-separated list or "all"; empty means default_profile, else every
+// profile. It returns nothing when the file has no profiles.
+func (c *Config) selectProfiles(names string) ([]string, error) {
+	if names == "" {
+		names = c.DefaultProfile
+	}
+	if names == "" || names == "all" {
+		all := make([]string, 0, len(c.Profiles))
+		for name := range c.Profiles {
+			all = append(all, name)
+		}
+		sort.Strings(all)
+		return all, nil
+	}
+
+	var selected []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := c.Profiles[name]; !ok {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+		seen[name] = true
+		selected = append(selected, name)
+	}
+	return selected, nil
+}
+
+// Resolve returns the settings of every selected profile, or of the
+// unnamed router described by .env and the environment when the config
+// file has none. Each is merged from, lowest to highest priority: built-in
+// defaults, the .env file, the config file (top level, then the profile),
+// the process environment and command-line flags.
+//
+// With several profiles the environment cannot tell the routers apart, so
+// its IP, UNICOM_USER, PASSWORD and PASSWORD_HASH only fill in what a profile leaves
+// unset.
+func (c *Config) Resolve(profiles string, dotenv map[string]string, getenv func(string) string, flags PolicyFlags) ([]Settings, error) {
+	names, err := c.selectProfiles(profiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		s, err := c.resolve("", Profile{}, dotenv, getenv, flags, false)
+		if err != nil {
+			return nil, err
+		}
+		return []Settings{s}, nil
+	}
+
+	var all []Settings
+	for _, name := range names {
+		s, err := c.resolve(name, c.Profiles[name], dotenv, getenv, flags, len(names) > 1)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		all = append(all, s)
+	}
+	return all, nil
+}
+
+func (c *Config) resolve(name string, p Profile, dotenv map[string]string, getenv func(string) string, flags PolicyFlags, shared bool) (Settings, error) {
+	s := Settings{Profile: name, Policy: DefaultPolicy}
+
+	if err := s.loadEnv(func(k string) string { return dotenv[k] }); err != nil {
+		return s, fmt.Errorf(".env: %v", err)
+	}
+	if shared {
+		s.loadConnection(getenv)
+	}
+
+	if c.APIToken != "" {
+		s.APIToken = c.APIToken
+	}
+	if c.Debug {
+		s.Debug = true
+	}
+	setIfNotEmpty(&s.IP, p.IP)
+	setIfNotEmpty(&s.User, p.User)
+	s.setPassword(p.Password, p.PasswordHash)
+	if err := s.Policy.LoadMap(p.Policy); err != nil {
+		return s, err
+	}
+
+	if !shared {
+		s.loadConnection(getenv)
+	}
+	if err := s.loadShared(getenv); err != nil {
+		return s, err
+	}
+	if err := s.Policy.LoadFlags(flags); err != nil {
+		return s, err
+	}
+
+	if s.IP == "" {
+		return s, fmt.Errorf("no router IP configured: set IP in .env or the environment, or ip in a config profile")
+	}
+	if err := s.Policy.Validate(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func (s *Settings) loadEnv(getenv func(string) string) error {
+	s.loadConnection(getenv)
+	return s.loadShared(getenv)
+}
+
+// loadConnection reads the settings that identify one router.
+func (s *Settings) loadConnection(getenv func(string) string) {
+	setIfNotEmpty(&s.IP, getenv("IP"))
+	setIfNotEmpty(&s.User, getenv("UNICOM_USER"))
+	s.setPassword(getenv("PASSWORD"), getenv("PASSWORD_HASH"))
+}
+
+// setPassword applies one layer's credentials: a plaintext password is
+// hashed, and an explicit hash from the same layer takes precedence.
+func (s *Settings) setPassword(password, hash string) {
+	if password != "" {
+		s.PasswordHash = vn007.HashPassword(password)
+	}
+	setIfNotEmpty(&s.PasswordHash, hash)
+}
+
+// loadShared reads the settings that apply to every router.
+func (s *Settings) loadShared(getenv func(string) string) error {
+	setIfNotEmpty(&s.APIToken, getenv("API_TOKEN"))
+	if v := getenv("DEBUG"); v != "" {
+		s.Debug = v == "Yes"
+	}
+	return s.Policy.LoadEnv(getenv)
+}
+
+func setIfNotEmpty(dst *string, v string) {
+	if v != "" {
+		*dst = v
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestPolicy_EnvThenFlags(t *testing.T) {
+	env := map[string]string{
+		"RECOVER_TIME":  "30s",
+		"RECOVER_BYTES": "5000000",
+		"RSRQ_BANDS":    "-20, -12, -6",
+	}
+	policy := DefaultPolicy
+	if err := policy.LoadEnv(func(k string) string { return env[k] }); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterPolicyFlags(fs)
+	if err := fs.Parse([]string{"--recover-time", "1m", "--max-retries", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.LoadFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if policy.RecoverTime != time.Minute {
+		t.Errorf("RecoverTime = %s, want flag value 1m", policy.RecoverTime)
+	}
+	if policy.RecoverBytes != 5000000 {
+		t.Errorf("RecoverBytes = %d, want env value", policy.RecoverBytes)
+	}
+	if policy.MaxRetries != 3 {
+		t.Errorf("MaxRetries = %d, want 3", policy.MaxRetries)
+	}
+	if policy.RSRQBands != (Bands{-20, -12, -6}) {
+		t.Errorf("RSRQBands = %v", policy.RSRQBands)
+	}
+	if policy.RebootSleep != DefaultPolicy.RebootSleep {
+		t.Errorf("RebootSleep = %s, want default", policy.RebootSleep)
+	}
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+func TestPolicy_LoadEnvReportsBadValues(t *testing.T) {
+	env := map[string]string{"REBOOT_SLEEP": "soon", "RSRQ_5G_BANDS": "-1,-2"}
+	policy := DefaultPolicy
+	err := policy.LoadEnv(func(k string) string { return env[k] })
+	if err == nil || !strings.Contains(err.Error(), "REBOOT_SLEEP") || !strings.Contains(err.Error(), "RSRQ_5G_BANDS") {
+		t.Errorf("LoadEnv = %v, want errors for both settings", err)
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := DefaultPolicy
+	policy.MaxRetries = 0
+	policy.MaxDelay = policy.BaseDelay / 2
+	policy.RSRQ5GBands = Bands{-5, -9, -15}
+	policy.MaxRebootsDay = -1
+	policy.MaxRebootBackoff = time.Minute
+
+	err := policy.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted bad policy")
+	}
+	for _, want := range []string{"max-retries", "max-delay", "rsrq-5g-bands", "max-reboots-day", "max-reboot-backoff"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error %q does not mention %s", err, want)
+		}
+	}
+	if err := DefaultPolicy.Validate(); err != nil {
+		t.Errorf("default policy invalid: %v", err)
+	}
+}
+
+func TestPolicy_ExperimentalControls(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterPolicyFlags(fs)
+	if err := fs.Parse([]string{"--recovery-steps", "reattach,data,reboot", "--budget-4g-month", "1000", "--nr-band-lock", "5m"}); err != nil {
+		t.Fatal(err)
+	}
+	policy := DefaultPolicy
+	if err := policy.LoadFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+	err := policy.Validate()
+	for _, want := range []string{"step reattach", "step data", "budget-4g", "nr-band-lock"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want %s refused without experimental", err, want)
+		}
+	}
+
+	if err := fs.Parse([]string{"--experimental"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.LoadFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Validate with --experimental = %v", err)
+	}
+}
+
+func TestPolicy_RebootBackoff(t *testing.T) {
+	policy := DefaultPolicy
+	policy.RebootBackoff, policy.MaxRebootBackoff = time.Minute, 5*time.Minute
+	for n, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 4: 5 * time.Minute, 40: 5 * time.Minute} {
+		if got := policy.rebootBackoff(n); got != want {
+			t.Errorf("rebootBackoff(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestBands_Bars(t *testing.T) {
+	// The historical 4G thresholds: below -15 is one bar.
+	bands := DefaultPolicy.RSRQBands
+	for v, want := range map[int]int{-20: 1, -16: 1, -15: 2, -10: 2, -9: 3, -5: 3, -4: 4} {
+		if got := bands.Bars(v); got != want {
+			t.Errorf("Bars(%d) = %d, want %d", v, got, want)
+		}
+	}
+}
+
+func TestPolicyFlags_RejectBadValueAtParse(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(new(strings.Builder))
+	RegisterPolicyFlags(fs)
+	if err := fs.Parse([]string{"--base-delay", "often"}); err == nil {
+		t.Error("Parse accepted an invalid duration")
+	}
+}
+
+const testConfig = `
+default_profile: home
+api_token: from-file
+profiles:
+  home:
+    ip: 192.168.0.1
+    user: superadmin
+    password_hash: aG9tZQ==
+    policy:
+      recover_time: 30s
+      rsrq_bands: -18,-12,-6
+  office:
+    ip: 10.0.0.1
+    password_hash: b2ZmaWNl
+    policy:
+      max_retries: "8"
+`
+
+func writeTestConfig(t *testing.T, content string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func noEnv(string) string { return "" }
+
+func TestConfig_DefaultProfile(t *testing.T) {
+	cfg := writeTestConfig(t, testConfig)
+	dotenv := map[string]string{"IP": "192.168.8.1", "UNICOM_USER": "dotenv-user", "DEBUG": "Yes"}
+
+	all, err := cfg.Resolve("", dotenv, noEnv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("resolved %d profiles, want the default one", len(all))
+	}
+	s := all[0]
+	if s.Profile != "home" || s.IP != "192.168.0.1" || s.User != "superadmin" || s.PasswordHash != "aG9tZQ==" {
+		t.Errorf("settings = %+v, want the home profile", s)
+	}
+	if s.APIToken != "from-file" || !s.Debug {
+		t.Errorf("APIToken = %q, Debug = %v", s.APIToken, s.Debug)
+	}
+	if s.Policy.RecoverTime != 30*time.Second || s.Policy.RSRQBands != (Bands{-18, -12, -6}) {
+		t.Errorf("policy = %+v", s.Policy)
+	}
+}
+
+func TestConfig_Precedence(t *testing.T) {
+	cfg := writeTestConfig(t, testConfig)
+	env := map[string]string{"PASSWORD_HASH": "ZW52", "MAX_RETRIES": "2", "BASE_DELAY": "2s"}
+	dotenv := map[string]string{"UNICOM_USER": "dotenv-user", "MAX_DELAY": "10s"}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterPolicyFlags(fs)
+	if err := fs.Parse([]string{"--base-delay", "3s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := cfg.Resolve("office", dotenv, func(k string) string { return env[k] }, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := all[0]
+	if s.IP != "10.0.0.1" {
+		t.Errorf("IP = %q, want the office profile", s.IP)
+	}
+	if s.User != "dotenv-user" {
+		t.Errorf("User = %q, want .env value where the profile has none", s.User)
+	}
+	if s.PasswordHash != "ZW52" {
+		t.Errorf("PasswordHash = %q, want env over profile", s.PasswordHash)
+	}
+	if s.Policy.MaxRetries != 2 {
+		t.Errorf("MaxRetries = %d, want env over profile", s.Policy.MaxRetries)
+	}
+	if s.Policy.BaseDelay != 3*time.Second {
+		t.Errorf("BaseDelay = %s, want flag over env", s.Policy.BaseDelay)
+	}
+	if s.Policy.MaxDelay != 10*time.Second {
+		t.Errorf("MaxDelay = %s, want .env value", s.Policy.MaxDelay)
+	}
+}
+
+func TestConfig_SeveralProfiles(t *testing.T) {
+	cfg := writeTestConfig(t, testConfig)
+	env := map[string]string{"IP": "172.16.0.1", "UNICOM_USER": "env-user", "MAX_RETRIES": "3"}
+
+	for _, profiles := range []string{"home,office", "all"} {
+		all, err := cfg.Resolve(profiles, nil, func(k string) string { return env[k] }, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 2 || all[0].Profile != "home" || all[1].Profile != "office" {
+			t.Fatalf("--profile %s resolved %+v", profiles, all)
+		}
+		if all[0].IP != "192.168.0.1" || all[1].IP != "10.0.0.1" {
+			t.Errorf("IPs = %s, %s, want each profile's own", all[0].IP, all[1].IP)
+		}
+		if all[0].User != "superadmin" || all[1].User != "env-user" {
+			t.Errorf("users = %s, %s, want env only where the profile has none", all[0].User, all[1].User)
+		}
+		if all[0].Policy.MaxRetries != 3 || all[1].Policy.MaxRetries != 3 {
+			t.Errorf("env MAX_RETRIES not applied to every profile")
+		}
+	}
+
+	cfg = writeTestConfig(t, "profiles:\n  b:\n    ip: 2.2.2.2\n  a:\n    ip: 1.1.1.1\n")
+	all, err := cfg.Resolve("", nil, noEnv, nil)
+	if err != nil || len(all) != 2 || all[0].Profile != "a" {
+		t.Errorf("without default_profile = %+v, %v, want every profile sorted", all, err)
+	}
+}
+
+func TestConfig_Errors(t *testing.T) {
+	cfg := writeTestConfig(t, testConfig)
+	if _, err := cfg.Resolve("garage", nil, noEnv, nil); err == nil || !strings.Contains(err.Error(), "garage") {
+		t.Errorf("unknown profile: %v", err)
+	}
+
+	cfg = writeTestConfig(t, "profiles:\n  a:\n    policy:\n      recover_tiem: 1s\n")
+	if _, err := cfg.Resolve("", map[string]string{"IP": "1.1.1.1"}, noEnv, nil); err == nil || !strings.Contains(err.Error(), "recover_tiem") {
+		t.Errorf("unknown policy key: %v", err)
+	}
+
+	if _, err := (&Config{}).Resolve("", nil, noEnv, nil); err == nil || !strings.Contains(err.Error(), "IP") {
+		t.Errorf("missing IP: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("profile:\n  a: {}\n"), 0o600)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("LoadConfig accepted an unknown top-level key")
+	}
+}
+
+func TestLoadConfig_Missing(t *testing.T) {
+	cfg, err := LoadConfig(filepath.Join(t.TempDir(), "none.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := cfg.Resolve("", map[string]string{"IP": "192.168.0.1"}, noEnv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := all[0]; len(all) != 1 || s.Profile != "" || s.IP != "192.168.0.1" || !reflect.DeepEqual(s.Policy, DefaultPolicy) {
+		t.Errorf("settings = %+v, want .env IP and default policy", s)
+	}
+}
+
+func TestConfig_PlaintextPassword(t *testing.T) {
+	cfg := writeTestConfig(t, "profiles:\n  home:\n    ip: 192.168.0.1\n    password: "+vn007test.Password+"\n")
+	all, err := cfg.Resolve("", nil, noEnv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[0].PasswordHash != vn007test.PasswordHash {
+		t.Errorf("PasswordHash = %s, want hash of the profile password", all[0].PasswordHash)
+	}
+
+	cfg = writeTestConfig(t, testConfig)
+	env := map[string]string{"PASSWORD": vn007test.Password}
+	all, err = cfg.Resolve("home", nil, func(k string) string { return env[k] }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[0].PasswordHash != vn007test.PasswordHash {
+		t.Errorf("PasswordHash = %s, want env PASSWORD over profile password_hash", all[0].PasswordHash)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+// runHashPasswordCommand implements `vn007go hash-password`. It reads the
+// password without echo from a terminal, or as the first line of piped
+// input, and prints the PASSWORD_HASH value for it.
+func runHashPasswordCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("hash-password", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	password, err := readPassword(stdin, stdout, "Router password: ")
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return errors.New("empty password")
+	}
+	fmt.Fprintln(stdout, vn007.HashPassword(password))
+	return nil
+}
+
+// readPassword prompts for a secret on a terminal, or reads one line from
+// stdin otherwise.
+func readPassword(stdin io.Reader, prompt io.Writer, label string) (string, error) {
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(f.Fd()) {
+		fmt.Fprint(prompt, label)
+		b, err := term.ReadPassword(f.Fd())
+		fmt.Fprintln(prompt)
+		if err != nil {
+			return "", fmt.Errorf("error reading password: %v", err)
+		}
+		return string(b), nil
+	}
+
+	// Read byte by byte so a following prompt still finds its own line.
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := stdin.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading password: %v", err)
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
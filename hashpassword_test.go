@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestRunHashPasswordCommand(t *testing.T) {
+	var out strings.Builder
+	if err := runHashPasswordCommand(nil, strings.NewReader(vn007test.Password+"\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != vn007test.PasswordHash {
+		t.Errorf("output = %q, want %s", got, vn007test.PasswordHash)
+	}
+
+	if err := runHashPasswordCommand(nil, strings.NewReader(""), &out); err == nil {
+		t.Error("hashed an empty password")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// logSink reports monitor transitions as log lines. The monitor already
+// logs its own actions, so only state changes need adding here.
+type logSink struct{}
+
+func (logSink) Sample(Sample) {}
+
+func (logSink) Event(ev Event) {
+	if ev.Kind == EventStateChanged {
+		routerLogger(ev.Router).Info("state changed", "from", ev.From, "to", ev.To, "cause", ev.Cause)
+	}
+}
+
+// runHeadless runs the monitors without a terminal until ctx is cancelled,
+// e.g. by SIGTERM from systemd or docker stop.
+func runHeadless(ctx context.Context, monitors []*Monitor, logFile, logFormat string) error {
+	var out io.Writer = os.Stdout
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("error opening log file: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch logFormat {
+	case "text":
+		log.SetFormatter(log.TextFormatter)
+	case "logfmt":
+		log.SetFormatter(log.LogfmtFormatter)
+	case "json":
+		log.SetFormatter(log.JSONFormatter)
+	default:
+		return fmt.Errorf("unknown log format %q", logFormat)
+	}
+
+	log.SetOutput(out)
+	log.SetReportCaller(false)
+	log.SetReportTimestamp(true)
+	log.SetTimeFormat(time.RFC3339)
+
+	errs := make(chan error, len(monitors))
+	for _, monitor := range monitors {
+		monitor.AddSink(logSink{})
+		go func() {
+			monitor.logger().Info("monitor started", "url", monitor.client.URL)
+			err := monitor.Run(ctx)
+			monitor.logger().Info("monitor stopped", "reason", err)
+			errs <- err
+		}()
+	}
+
+	var result error
+	for range monitors {
+		if err := <-errs; !errors.Is(err, context.Canceled) && result == nil {
+			result = err
+		}
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestRunHeadless_StopsOnCancel(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFormatter(log.TextFormatter)
+	})
+
+	logFile := filepath.Join(t.TempDir(), "vn007go.log")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if err := runHeadless(ctx, []*Monitor{NewMonitor(router.Client(), DefaultPolicy, realClock{})}, logFile, "json"); err != nil {
+		t.Fatalf("runHeadless = %v, want nil after cancel", err)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"msg":"monitor started"`, `"msg":"monitor stopped"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("log file missing %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestRunHeadless_RejectsUnknownFormat(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+
+	if err := runHeadless(context.Background(), []*Monitor{NewMonitor(router.Client(), DefaultPolicy, realClock{})}, "", "xml"); err == nil {
+		t.Fatal("runHeadless accepted log format xml")
+	}
+}
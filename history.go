@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// historyEntry is one record of the history file.
+type historyEntry struct {
+	Router  string    `json:"router,omitempty"`
+	Time    time.Time `json:"time"`
+	Kind    EventKind `json:"kind"`
+	From    State     `json:"from"`
+	To      State     `json:"to"`
+	Cause   string    `json:"cause"`
+	Uptime  int       `json:"uptime"`
+	Bytes4G int       `json:"bytes_4g"`
+	RSRQ    int       `json:"rsrq"`
+	RSRQ5G  int       `json:"rsrq_5g"`
+	Error   string    `json:"error,omitempty"`
+}
+
+func newHistoryEntry(ev Event) historyEntry {
+	entry := historyEntry{
+		Router:  ev.Router,
+		Time:    ev.Time,
+		Kind:    ev.Kind,
+		From:    ev.From,
+		To:      ev.To,
+		Cause:   ev.Cause,
+		Uptime:  ev.Sample.Uptime,
+		Bytes4G: ev.Bytes4G,
+		RSRQ:    ev.Sample.RSRQ,
+		RSRQ5G:  ev.Sample.RSRQ5G,
+	}
+	if ev.Err != nil {
+		entry.Error = ev.Err.Error()
+	}
+	return entry
+}
+
+// defaultHistoryPath is where the history file lives unless --history says
+// otherwise.
+func defaultHistoryPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "history.jsonl"
+	}
+	return filepath.Join(dir, "vn007go", "history.jsonl")
+}
+
+// HistoryStore appends reboots, 5G losses and recoveries, login failures
+// and 5G unavailable alerts to a JSONL file. State changes are not
+// recorded.
+type HistoryStore struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenHistory opens (creating if needed) the history file at path.
+func OpenHistory(path string) (*HistoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating history directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening history file: %v", err)
+	}
+	return &HistoryStore{path: path, f: f}, nil
+}
+
+func (h *HistoryStore) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.f.Close()
+}
+
+func (h *HistoryStore) Sample(Sample) {}
+
+func (h *HistoryStore) Event(ev Event) {
+	if ev.Kind == EventStateChanged {
+		return
+	}
+	if err := h.Append(newHistoryEntry(ev)); err != nil {
+		// Logging from inside a sink would loop back through the TUI, so
+		// report straight to stderr.
+		fmt.Fprintln(os.Stderr, "vn007go: writing history:", err)
+	}
+}
+
+// Append writes one entry to the file.
+func (h *HistoryStore) Append(entry historyEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.f.Write(append(line, '\n'))
+	return err
+}
+
+// Entries returns every entry recorded at or after since, oldest first.
+func (h *HistoryStore) Entries(since time.Time) ([]historyEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return ReadHistory(h.path, since)
+}
+
+// ReadHistory reads the entries in the history file at path recorded at
+// or after since. A missing file has no entries.
+func ReadHistory(path string, since time.Time) ([]historyEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening history file: %v", err)
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history file: %v", err)
+	}
+	return entries, nil
+}
+
+// runHistoryCommand implements `vn007go history`.
+func runHistoryCommand(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	path := fs.String("history", defaultHistoryPath(), "history file to read")
+	since := fs.Duration("since", 0, "only show events from this long ago, e.g. 24h or 168h (default: everything)")
+	router := fs.String("router", "", "only show events of this router profile")
+	asJSON := fs.Bool("json", false, "print raw JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	entries, err := ReadHistory(*path, from)
+	if err != nil {
+		return err
+	}
+	if *router != "" {
+		entries = filterRouter(entries, *router)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	counts := map[EventKind]int{}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tROUTER\tEVENT\tCAUSE\tUPTIME\t4G MB\tRSRQ\tRSRQ 5G")
+	for _, e := range entries {
+		counts[e.Kind]++
+		hh, mm, ss := secondsToTime(e.Uptime)
+		router := e.Router
+		if router == "" {
+			router = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d:%02d:%02d\t%.2f\t%d\t%d\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"), router, e.Kind, e.Cause,
+			hh, mm, ss, float32(e.Bytes4G)*0.000001, e.RSRQ, e.RSRQ5G)
+	}
+	w.Flush()
+
+	fmt.Fprintf(stdout, "\n%d 5G losses, %d recoveries, %d reboots, %d login failures, %d 5G unavailable alerts\n",
+		counts[Event5GLost], counts[Event5GRecovered], counts[EventReboot], counts[EventLoginFailed], counts[Event5GUnavailable])
+	return nil
+}
+
+// filterRouter keeps the entries recorded for router.
+func filterRouter(entries []historyEntry, router string) []historyEntry {
+	var kept []historyEntry
+	for _, e := range entries {
+		if e.Router == router {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHistory(t *testing.T) *HistoryStore {
+	t.Helper()
+	history, err := OpenHistory(filepath.Join(t.TempDir(), "vn007go", "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { history.Close() })
+	return history
+}
+
+func TestHistoryStore_RecordsOutageCycle(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+	history := newTestHistory(t)
+	m.AddSink(history)
+
+	step(t, m, clock)
+	router.Drop5G()
+	step(t, m, clock)
+	router.Advance(recoverSecs, 4000, 1000)
+	step(t, m, clock)
+
+	entries, err := history.Entries(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, e := range entries {
+		kinds = append(kinds, string(e.Kind))
+	}
+	if got := strings.Join(kinds, ","); got != "5g_lost,reboot" {
+		t.Fatalf("recorded kinds = %s, want 5g_lost,reboot", got)
+	}
+
+	reboot := entries[1]
+	if reboot.Cause != "5G not recovered" || reboot.Uptime != 605 || reboot.Bytes4G != 5000 || reboot.RSRQ != -10 || reboot.RSRQ5G != -11 {
+		t.Errorf("reboot entry = %+v", reboot)
+	}
+	if want := m.Status().LastReboot; !reboot.Time.Equal(want) {
+		t.Errorf("reboot time = %s, want %s", reboot.Time, want)
+	}
+}
+
+func TestHistoryStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	at := time.Date(2024, 10, 1, 4, 0, 0, 0, time.UTC)
+
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history.Event(Event{Time: at.Add(-48 * time.Hour), Kind: EventLoginFailed, Err: errors.New("authentication failed")})
+	history.Event(Event{Time: at, Kind: EventReboot, Cause: "manual"})
+	history.Close()
+
+	entries, err := ReadHistory(path, at.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Kind != EventReboot || entries[0].Cause != "manual" {
+		t.Errorf("entries since 24h = %+v, want the reboot only", entries)
+	}
+
+	all, _ := ReadHistory(path, time.Time{})
+	if len(all) != 2 || all[0].Error != "authentication failed" {
+		t.Errorf("all entries = %+v", all)
+	}
+}
+
+func TestReadHistory_MissingFile(t *testing.T) {
+	entries, err := ReadHistory(filepath.Join(t.TempDir(), "nope.jsonl"), time.Time{})
+	if err != nil || entries != nil {
+		t.Errorf("ReadHistory(missing) = %v, %v", entries, err)
+	}
+}
+
+func TestRunHistoryCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history.Event(Event{Time: time.Now(), Kind: Event5GLost, Cause: "FREQ_5G missing", Sample: Sample{Uptime: 3725, RSRQ: -12}})
+	history.Event(Event{Time: time.Now(), Kind: EventReboot, Cause: "5G not recovered", Bytes4G: 2500000})
+	history.Close()
+
+	var out strings.Builder
+	if err := runHistoryCommand([]string{"--history", path, "--since", "1h"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"5g_lost", "FREQ_5G missing", "1:02:05", "2.50", "1 5G losses, 0 recoveries, 1 reboots, 0 login failures"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
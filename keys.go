@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+)
+
+// keyMap holds the TUI keybindings. Reboot and pause act on the selected
+// router, which tab moves between.
+type keyMap struct {
+	Reboot     key.Binding
+	Pause      key.Binding
+	NextRouter key.Binding
+	Settings   key.Binding
+	Details    key.Binding
+	Network    key.Binding
+	Debug      key.Binding
+	ClearLogs  key.Binding
+	Search     key.Binding
+	Level      key.Binding
+	Follow     key.Binding
+	Export     key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	PageUp     key.Binding
+	PageDown   key.Binding
+	Top        key.Binding
+	Bottom     key.Binding
+	Apply      key.Binding
+	Close      key.Binding
+	Help       key.Binding
+	Quit       key.Binding
+	Confirm    key.Binding
+	Cancel     key.Binding
+}
+
+var keys = keyMap{
+	Reboot:     key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reboot")),
+	Pause:      key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "pause/resume auto-reboot")),
+	NextRouter: key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next router")),
+	Settings:   key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "settings")),
+	Details:    key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "details")),
+	Network:    key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "network")),
+	Debug:      key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "debug logs on/off")),
+	ClearLogs:  key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "clear logs")),
+	Search:     key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search logs")),
+	Level:      key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "minimum log level")),
+	Follow:     key.NewBinding(key.WithKeys("F"), key.WithHelp("F", "follow new logs on/off")),
+	Export:     key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "export shown logs")),
+	Up:         key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+	Down:       key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+	PageUp:     key.NewBinding(key.WithKeys("pgup", "b"), key.WithHelp("pgup/b", "page up")),
+	PageDown:   key.NewBinding(key.WithKeys("pgdown", "f", " "), key.WithHelp("pgdn/f", "page down")),
+	Top:        key.NewBinding(key.WithKeys("home", "g"), key.WithHelp("home/g", "oldest log")),
+	Bottom:     key.NewBinding(key.WithKeys("end", "G"), key.WithHelp("end/G", "newest log")),
+	Apply:      key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "apply network entry")),
+	Close:      key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "close pane")),
+	Help:       key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "help")),
+	Quit:       key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
+	Confirm:    key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "yes")),
+	Cancel:     key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", "no")),
+}
+
+// ShortHelp is the footer line.
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Reboot, k.Pause, k.Search, k.Details, k.Network, k.Help, k.Quit}
+}
+
+// FullHelp is the help overlay, one column per group.
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Reboot, k.Pause, k.NextRouter, k.Debug},
+		{k.Settings, k.Details, k.Network, k.Apply, k.Close},
+		{k.Search, k.Level, k.Follow, k.Export, k.ClearLogs},
+		{k.Up, k.Down, k.PageUp, k.PageDown, k.Top, k.Bottom},
+		{k.Help, k.Quit},
+	}
+}
+
+// viewportKeys scrolls the log pane. Half page keys are left out, as the
+// viewport defaults clash with the pane keys.
+func (k keyMap) viewportKeys() viewport.KeyMap {
+	return viewport.KeyMap{
+		Up:       k.Up,
+		Down:     k.Down,
+		PageUp:   k.PageUp,
+		PageDown: k.PageDown,
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/help"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+func press(m model, keys ...string) model {
+	for _, k := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		if k == "tab" {
+			msg = tea.KeyMsg{Type: tea.KeyTab}
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func newTestModel(monitors ...*Monitor) model {
+	m := model{minLevel: log.DebugLevel, search: newLogSearch(), follow: true, help: help.New()}
+	for _, monitor := range monitors {
+		m.routers = append(m.routers, newRouterView(monitor))
+	}
+	return m
+}
+
+func TestKeys_RebootNeedsConfirmation(t *testing.T) {
+	monitor, _, _, _ := newTestMonitor(t)
+	m := newTestModel(monitor)
+
+	m = press(m, "r")
+	if m.confirm == nil || monitor.takeRebootRequest() {
+		t.Fatal("r rebooted without asking")
+	}
+	m = press(m, "n")
+	if m.confirm != nil || monitor.takeRebootRequest() {
+		t.Fatal("n did not cancel the reboot")
+	}
+	m = press(m, "r", "y")
+	if m.confirm != nil || !monitor.takeRebootRequest() {
+		t.Error("y did not request the reboot")
+	}
+}
+
+func TestKeys_PauseSelectedRouter(t *testing.T) {
+	home, _, _, _ := newTestMonitor(t)
+	home.SetName("home")
+	office, _, _, _ := newTestMonitor(t)
+	office.SetName("office")
+	m := newTestModel(home, office)
+
+	m = press(m, "tab", "p")
+	if home.Paused() || !office.Paused() {
+		t.Fatalf("paused home = %v, office = %v; want the selected router only", home.Paused(), office.Paused())
+	}
+	m = press(m, "p")
+	if office.Paused() {
+		t.Error("p did not resume")
+	}
+}
+
+func TestKeys_HelpAndClear(t *testing.T) {
+	monitor, _, _, _ := newTestMonitor(t)
+	m := newTestModel(monitor)
+	m.logs.add(newLogEntry("a"))
+	m.logs.add(newLogEntry("b"))
+
+	m = press(m, "?")
+	if !m.help.ShowAll {
+		t.Fatal("? did not open the help")
+	}
+	m = press(m, "?", "c")
+	if m.help.ShowAll || len(m.logs.all()) != 0 {
+		t.Errorf("help shown = %v, logs = %v; want help closed and logs cleared", m.help.ShowAll, m.logs.all())
+	}
+}
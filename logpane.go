@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// maxLogs is how many log entries the log pane keeps.
+const maxLogs = 5000
+
+// logEntry is one structured log record, parsed from the JSON the logger
+// writes in TUI mode.
+type logEntry struct {
+	Time    string
+	Level   log.Level
+	Message string
+	Fields  [][2]string // key and value, sorted by key
+
+	text, styledText string // formatted once, as the pane redraws often
+	lower            string // text in lower case, for search
+}
+
+// parseLogLine reads a line written by log.JSONFormatter. Anything else is
+// kept as an info message, so no output is lost.
+func parseLogLine(line string) logEntry {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return logEntry{Level: log.InfoLevel, Message: line}
+	}
+
+	text := func(key string) string {
+		v, ok := raw[key]
+		if !ok {
+			return ""
+		}
+		delete(raw, key)
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return string(v)
+		}
+		return s
+	}
+	entry := logEntry{Time: text(log.TimestampKey), Message: text(log.MessageKey)}
+	level, err := log.ParseLevel(text(log.LevelKey))
+	if err != nil {
+		level = log.InfoLevel
+	}
+	entry.Level = level
+	if prefix := text(log.PrefixKey); prefix != "" {
+		entry.Message = prefix + " " + entry.Message
+	}
+
+	names := make([]string, 0, len(raw))
+	for k := range raw {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		entry.Fields = append(entry.Fields, [2]string{k, text(k)})
+	}
+	return entry
+}
+
+// newLogEntry parses line and formats it for display and export.
+func newLogEntry(line string) logEntry {
+	e := parseLogLine(line)
+	e.text = e.format(func(label string) string { return label })
+	e.styledText = e.format(func(label string) string {
+		if ls, ok := levelStyles[e.Level]; ok {
+			return ls.style(label)
+		}
+		return label
+	})
+	e.lower = strings.ToLower(e.text)
+	return e
+}
+
+// levelStyles style the level label of an entry.
+var levelStyles = map[log.Level]struct {
+	label string
+	style func(...string) string
+}{
+	log.DebugLevel: {"DEBU", debugStyle.Render},
+	log.InfoLevel:  {"INFO", infoStyle.Render},
+	log.WarnLevel:  {"WARN", warnStyle.Render},
+	log.ErrorLevel: {"ERRO", errorStyle.Render},
+	log.FatalLevel: {"FATA", fatalStyle.Render},
+}
+
+func (e logEntry) format(level func(label string) string) string {
+	var b strings.Builder
+	if e.Time != "" {
+		b.WriteString(e.Time + " ")
+	}
+	label := strings.ToUpper(e.Level.String())
+	if ls, ok := levelStyles[e.Level]; ok {
+		label = ls.label
+	}
+	b.WriteString(level(label) + " " + e.Message)
+	for _, f := range e.Fields {
+		value := f[1]
+		if strings.ContainsAny(value, " \t") {
+			value = fmt.Sprintf("%q", value)
+		}
+		b.WriteString(" " + f[0] + "=" + value)
+	}
+	return b.String()
+}
+
+// matches reports whether the entry mentions query, which must be in lower
+// case.
+func (e logEntry) matches(query string) bool {
+	return query == "" || strings.Contains(e.lower, query)
+}
+
+// logRing keeps the newest maxLogs entries, oldest first.
+type logRing struct {
+	entries []logEntry
+	start   int
+}
+
+// add appends e and returns the entry it pushed out, if the ring was full.
+func (r *logRing) add(e logEntry) (logEntry, bool) {
+	if len(r.entries) < maxLogs {
+		r.entries = append(r.entries, e)
+		return logEntry{}, false
+	}
+	dropped := r.entries[r.start]
+	r.entries[r.start] = e
+	r.start = (r.start + 1) % maxLogs
+	return dropped, true
+}
+
+func (r *logRing) all() []logEntry {
+	return append(append([]logEntry(nil), r.entries[r.start:]...), r.entries[:r.start]...)
+}
+
+func (r *logRing) clear() {
+	r.entries, r.start = nil, 0
+}
+
+// shows reports whether e passes the level filter and search.
+func (m model) shows(e logEntry) bool {
+	return e.Level >= m.minLevel && e.matches(strings.ToLower(m.search.Value()))
+}
+
+// visibleLogs returns the entries that pass the level filter and search.
+func (m model) visibleLogs() []logEntry {
+	var visible []logEntry
+	for _, e := range m.logs.all() {
+		if m.shows(e) {
+			visible = append(visible, e)
+		}
+	}
+	return visible
+}
+
+// addLog stores a new entry and adds it to the pane if it passes the
+// filters, without going over the other entries again.
+func (m *model) addLog(e logEntry) {
+	if dropped, ok := m.logs.add(e); ok && len(m.shown) > 0 && m.shows(dropped) {
+		m.shown = m.shown[1:]
+	}
+	if m.shows(e) {
+		m.shown = append(m.shown, e.styledText)
+	}
+	m.showLogs()
+}
+
+// refreshLogs filters every entry afresh, for when the filters change or
+// the log is cleared.
+func (m *model) refreshLogs() {
+	var shown []string
+	for _, e := range m.visibleLogs() {
+		shown = append(shown, e.styledText)
+	}
+	m.shown = shown
+	m.showLogs()
+}
+
+// showLogs puts the shown lines in the pane, staying at the newest entry
+// in follow mode.
+func (m *model) showLogs() {
+	m.viewport.SetContent(strings.Join(m.shown, "\n"))
+	if m.follow {
+		m.viewport.GotoBottom()
+	}
+}
+
+// logFilterLevels are the minimum levels the level filter steps through.
+var logFilterLevels = []log.Level{log.DebugLevel, log.InfoLevel, log.WarnLevel, log.ErrorLevel}
+
+// logKey handles the log pane keys and reports whether msg was used. While
+// the search box has focus it takes every key but ctrl+c, so q can be
+// searched for and the program can still be quit.
+func (m *model) logKey(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if m.search.Focused() {
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return false, nil
+		case tea.KeyEnter:
+			m.search.Blur()
+		case tea.KeyEsc:
+			m.search.Blur()
+			m.search.SetValue("")
+			m.refreshLogs()
+		default:
+			var cmd tea.Cmd
+			query := m.search.Value()
+			m.search, cmd = m.search.Update(msg)
+			if m.search.Value() != query {
+				m.refreshLogs()
+			}
+			return true, cmd
+		}
+		return true, nil
+	}
+
+	switch {
+	case key.Matches(msg, keys.Search):
+		m.search.Focus()
+		return true, nil
+	case key.Matches(msg, keys.Level):
+		for i, level := range logFilterLevels {
+			if level == m.minLevel {
+				m.minLevel = logFilterLevels[(i+1)%len(logFilterLevels)]
+				break
+			}
+		}
+	case key.Matches(msg, keys.Follow):
+		m.follow = !m.follow
+		if m.follow {
+			m.viewport.GotoBottom()
+		}
+		return true, nil
+	case key.Matches(msg, keys.Export):
+		path, err := m.exportLogs()
+		if err != nil {
+			log.Error("error exporting logs", "error", err)
+		} else {
+			log.Info("logs exported", "file", path)
+		}
+		return true, nil
+	case key.Matches(msg, keys.ClearLogs):
+		m.logs.clear()
+	case key.Matches(msg, keys.Top):
+		m.follow = false
+		m.viewport.GotoTop()
+		return true, nil
+	case key.Matches(msg, keys.Bottom):
+		m.follow = true
+		m.viewport.GotoBottom()
+		return true, nil
+	case key.Matches(msg, keys.Up, keys.PageUp):
+		m.follow = false
+		return false, nil
+	default:
+		return false, nil
+	}
+	m.refreshLogs()
+	return true, nil
+}
+
+// exportLogs writes the visible entries as text to a new file in the
+// working directory and returns its name.
+func (m model) exportLogs() (string, error) {
+	path := "vn007go-" + time.Now().Format("20060102-150405") + ".log"
+	var b strings.Builder
+	for _, e := range m.visibleLogs() {
+		b.WriteString(e.text + "\n")
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// newLogSearch returns the search box of the log pane.
+func newLogSearch() textinput.Model {
+	search := textinput.New()
+	search.Prompt = "/"
+	search.Placeholder = "search"
+	return search
+}
+
+// logStatus is the line above the log pane: how many entries show, the
+// filters and whether the pane follows new entries.
+func (m model) logStatus() string {
+	parts := []string{fmt.Sprintf("%d/%d", len(m.shown), len(m.logs.entries))}
+	parts = append(parts, "level ≥ "+m.minLevel.String())
+	if m.search.Focused() {
+		parts = append(parts, m.search.View())
+	} else if q := m.search.Value(); q != "" {
+		parts = append(parts, fmt.Sprintf("search %q", q))
+	}
+	if m.follow {
+		parts = append(parts, "following")
+	} else {
+		parts = append(parts, warnStyle.Render("scrolled back, F to follow"))
+	}
+	return logStyle.Render(titleStyle.Render("Logs") + " " + strings.Join(parts, " · "))
+}
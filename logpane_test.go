@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+func TestParseLogLine(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewWithOptions(&buf, log.Options{Formatter: log.JSONFormatter, ReportTimestamp: true, TimeFormat: "15:04:05"})
+	logger.With("router", "home").Warn("5G recovery", "downtime(sec)", 3, "note", "two words")
+
+	e := newLogEntry(strings.TrimSpace(buf.String()))
+	if e.Level != log.WarnLevel || e.Message != "5G recovery" || len(e.Time) != 8 {
+		t.Fatalf("entry = %+v", e)
+	}
+	want := [][2]string{{"downtime(sec)", "3"}, {"note", "two words"}, {"router", "home"}}
+	if !reflect.DeepEqual(e.Fields, want) {
+		t.Errorf("fields = %v, want %v", e.Fields, want)
+	}
+	if !strings.HasSuffix(e.text, `WARN 5G recovery downtime(sec)=3 note="two words" router=home`) {
+		t.Errorf("text = %q", e.text)
+	}
+
+	if e := newLogEntry("not json"); e.Level != log.InfoLevel || e.Message != "not json" {
+		t.Errorf("plain line = %+v, want kept as info", e)
+	}
+}
+
+func TestLogRing_KeepsNewest(t *testing.T) {
+	var r logRing
+	for i := 0; i < maxLogs+2; i++ {
+		r.add(logEntry{Message: string(rune('a' + i%26))})
+	}
+	all := r.all()
+	if len(all) != maxLogs || all[0].Message != "c" || all[maxLogs-1].Message != string(rune('a'+(maxLogs+1)%26)) {
+		t.Errorf("ring holds %d entries from %q, want the oldest two dropped", len(all), all[0].Message)
+	}
+}
+
+func TestLogPane_FiltersAndExports(t *testing.T) {
+	monitor, _, _, _ := newTestMonitor(t)
+	m := newTestModel(monitor)
+	for _, line := range []string{
+		`{"level":"debug","msg":"Total traffic"}`,
+		`{"level":"info","msg":"auto-reboot","router":"home"}`,
+		`{"level":"error","msg":"monitoring cycle failed","router":"office"}`,
+	} {
+		next, _ := m.Update(logMsg(line))
+		m = next.(model)
+	}
+	if len(m.shown) != 3 {
+		t.Fatalf("visible = %d, want every entry", len(m.shown))
+	}
+
+	m = press(m, "l", "l")
+	if m.minLevel != log.WarnLevel || len(m.shown) != 1 {
+		t.Errorf("level %s shows %d entries, want the error only", m.minLevel, len(m.shown))
+	}
+	m = press(m, "l", "l", "/", "h", "o", "m", "e")
+	if !m.search.Focused() || len(m.shown) != 1 {
+		t.Fatalf("search shows %d entries, want the home entry", len(m.shown))
+	}
+	m = press(m, "q")
+	if m.search.Value() != "homeq" {
+		t.Errorf("search = %q, want keys typed into the box", m.search.Value())
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC}); cmd == nil || cmd() != tea.Quit() {
+		t.Error("ctrl+c did not quit while searching")
+	}
+
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	m.search.SetValue("home")
+	path, err := m.exportLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if got := strings.TrimSpace(string(data)); got != "INFO auto-reboot router=home" {
+		t.Errorf("export = %q, want the shown entry as text", got)
+	}
+}
+
+func TestLogPane_FollowMode(t *testing.T) {
+	monitor, _, _, _ := newTestMonitor(t)
+	m := newTestModel(monitor)
+	m = press(m, "F")
+	if m.follow {
+		t.Fatal("F did not stop following")
+	}
+	m = press(m, "G")
+	if !m.follow {
+		t.Error("end did not resume following")
+	}
+}
+
+func TestLogPane_AddsIncrementally(t *testing.T) {
+	monitor, _, _, _ := newTestMonitor(t)
+	m := newTestModel(monitor)
+	m.minLevel = log.WarnLevel
+	for i := 0; i < maxLogs+3; i++ {
+		level := "info"
+		if i%2 == 0 {
+			level = "warn"
+		}
+		m.addLog(newLogEntry(fmt.Sprintf(`{"level":%q,"msg":"line %d"}`, level, i)))
+	}
+	visible := m.visibleLogs()
+	if len(m.shown) != len(visible) || m.shown[0] != visible[0].styledText || m.shown[len(m.shown)-1] != visible[len(visible)-1].styledText {
+		t.Errorf("pane shows %d lines from %q, want the %d filtered entries still in the ring", len(m.shown), m.shown[0], len(visible))
+	}
+}
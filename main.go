@@ -1,31 +1,27 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
-	"regexp"
-	"strconv"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/joho/godotenv"
-)
-
-// Define regular expressions for log levels
-var (
-	infoRegex  = regexp.MustCompile(`(?i)\bINFO\b`)
-	warnRegex  = regexp.MustCompile(`(?i)\bWARN\b`)
-	errorRegex = regexp.MustCompile(`(?i)\bERRO\b`)
-	fatalRegex = regexp.MustCompile(`(?i)\bFATA\b`)
-	debugRegex = regexp.MustCompile(`(?i)\bDEBU\b`)
+	"rpfilomeno.xyz/vn007go/vn007"
 )
 
 // Define styles using lipgloss or any other styling package
@@ -46,13 +42,31 @@ var (
 	textStyle  = lipgloss.NewStyle()
 	titleStyle = lipgloss.NewStyle().Bold(true)
 	logStyle   = lipgloss.NewStyle().PaddingLeft(2)
-	lastlog    string
 )
 
 // Model represents the application state
 type model struct {
-	viewport       viewport.Model
-	logs           []string
+	viewport     viewport.Model
+	logs         logRing
+	minLevel     log.Level // lowest level the log pane shows
+	search       textinput.Model
+	follow       bool     // whether the log pane stays at the newest entry
+	shown        []string // styled lines of the entries passing the filters
+	routers      []*routerView
+	showSettings bool
+	showDetail   bool
+	showNetwork  bool
+	selected     int         // router that reboot, pause and the network menu act on
+	menuItem     int         // highlighted network menu entry
+	confirm      *routerView // router awaiting reboot confirmation
+	help         help.Model
+	ready        bool
+}
+
+// routerView is the header state of one monitored router.
+type routerView struct {
+	monitor        *Monitor
+	name           string
 	freqValue      string
 	freq5GValue    string
 	txBytes        int
@@ -61,83 +75,67 @@ type model struct {
 	rsrq5GValue    int
 	uptimeValue    int
 	lastRebootTime string
-	ready          bool
+	state          State
+	drops          []time.Time // 5G losses from the history file and this run
+	reboots        []time.Time
+	policy         Policy
+	detail         Detail
+	usage          Usage
+	trend          *Trend
+	rates          Throughput
+}
+
+func newRouterView(monitor *Monitor) *routerView {
+	return &routerView{
+		monitor:        monitor,
+		name:           monitor.Name(),
+		policy:         monitor.policy,
+		freq5GValue:    "NA",
+		uptimeValue:    0,
+		lastRebootTime: "NONE",
+		trend:          NewTrend(),
+	}
+}
+
+// router returns the header of the named router.
+func (m model) router(name string) *routerView {
+	for _, r := range m.routers {
+		if r.name == name {
+			return r
+		}
+	}
+	return m.routers[0]
 }
 
 // Message types for the TUI
-type freqUpdateMsg string
-type freq5gUpdateMsg string
-type uptimeUpdateMsg string
-type lastRebootTimeMsg string
 type logMsg string
-type rxMsg string
-type txMsg string
-type rsrqMsg string
-type rsrq5gMsg string
-
-type LoginPayload struct {
-	Cmd           int    `json:"cmd"`
-	Method        string `json:"method"`
-	Language      string `json:"language"`
-	SessionId     string `json:"sessionId"`
-	Username      string `json:"username"`
-	Passwd        string `json:"passwd"`
-	IsAutoUpgrade string `json:"isAutoUpgrade"`
-}
-type MonitorPayload struct {
-	Cmd       int    `json:"cmd"`
-	Method    string `json:"method"`
-	Language  string `json:"language"`
-	SessionId string `json:"sessionId"`
-}
-
-type RebootPayload struct {
-	Cmd        int    `json:"cmd"`
-	RebootType int    `json:"rebootType"`
-	Method     string `json:"method"`
-	SessionId  string `json:"sessionId"`
-	Language   string `json:"language"`
-}
-
-type GetInfoPayload struct {
-	Cmd       int    `json:"cmd"`
-	Method    string `json:"method"`
-	SessionId string `json:"sessionId"`
-	Language  string `json:"language"`
-}
-type ResponseData struct {
-	FREQ_5G   interface{} `json:"FREQ_5G"`
-	FREQ      interface{} `json:"FREQ"`
-	Success   bool        `json:"success"`
-	Uptime    interface{} `json:"uptime"`
-	SessionId interface{} `json:"sessionId"`
-	RSRQ      interface{} `json:"RSRQ"`
-	RSRQ_5G   interface{} `json:"RSRQ_5G"`
-	WAN_rX    interface{} `json:"wan_rx_bytes"`
-	WAN_tX    interface{} `json:"wan_tx_bytes"`
-}
-
-const (
-	maxRetries   = 5
-	baseDelay    = 1 * time.Second
-	maxDelay     = 32 * time.Second
-	rebootSleep  = 60 * time.Second //sleep after reboot command is sent
-	rebootWait   = 60 * 4           // max uptime secs before it can reboot
-	recoverTime  = 5                //max secs to allow 5g signal to recover before rebooting
-	maxLogs      = 15               // Maximum number of logs to keep in memory
-	recoverBytes = 10000000         // Maximum bytes allowed to be used during %g recovery failure default: 10000000 (10MB)
-)
+type sampleMsg Sample
+type eventMsg Event
+
+// tuiSink forwards monitor output to the Bubble Tea program.
+type tuiSink struct {
+	program *tea.Program
+}
+
+func (s tuiSink) Sample(sample Sample) { s.program.Send(sampleMsg(sample)) }
+func (s tuiSink) Event(ev Event)       { s.program.Send(eventMsg(ev)) }
 
-// Custom writer for capturing log output
+// Custom writer for capturing log output. Every router logs through it,
+// so the last line, kept to drop repeats, is guarded by mu.
 type logWriter struct {
 	program *tea.Program
+	mu      sync.Mutex
+	last    string
 }
 
-func (l logWriter) Write(p []byte) (n int, err error) {
+func (l *logWriter) Write(p []byte) (n int, err error) {
 	log := strings.TrimSpace(string(p))
-	if lastlog != log {
+	l.mu.Lock()
+	repeat := log == l.last
+	l.last = log
+	l.mu.Unlock()
+	if !repeat {
 		l.program.Send(logMsg(log))
-		lastlog = log
 	}
 	return len(p), nil
 }
@@ -154,87 +152,116 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if msg.String() == "q" || msg.String() == "ctrl+c" {
+		if m.confirm != nil {
+			switch {
+			case key.Matches(msg, keys.Confirm):
+				log.Warn("manual reboot confirmed", "router", m.confirm.name)
+				m.confirm.monitor.RequestReboot()
+				m.confirm = nil
+			case key.Matches(msg, keys.Cancel):
+				m.confirm = nil
+			}
+			return m, nil
+		}
+		if m.showNetwork && m.networkKey(msg) {
+			return m, nil
+		}
+		if m.logPaneShown() {
+			if used, cmd := m.logKey(msg); used {
+				return m, cmd
+			}
+		}
+
+		switch {
+		case key.Matches(msg, keys.Quit):
 			return m, tea.Quit
+		case key.Matches(msg, keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+		case key.Matches(msg, keys.Close):
+			m.help.ShowAll = false
+			m.showSettings, m.showDetail, m.showNetwork = false, false, false
+		case key.Matches(msg, keys.Settings):
+			m.showSettings = !m.showSettings
+			m.showDetail = false
+			m.showNetwork = false
+		case key.Matches(msg, keys.Details):
+			m.showDetail = !m.showDetail
+			m.showSettings = false
+			m.showNetwork = false
+		case key.Matches(msg, keys.Network):
+			m.showNetwork = !m.showNetwork
+			m.showSettings = false
+			m.showDetail = false
+			if m.showNetwork {
+				for _, r := range m.routers {
+					r.monitor.RequestRadio(RadioChange{})
+				}
+			}
+		case key.Matches(msg, keys.NextRouter):
+			m.selected = (m.selected + 1) % len(m.routers)
+		case key.Matches(msg, keys.Reboot):
+			m.confirm = m.routers[m.selected]
+		case key.Matches(msg, keys.Pause):
+			r := m.routers[m.selected]
+			r.monitor.Pause(!r.monitor.Paused())
+		case key.Matches(msg, keys.Debug):
+			if log.GetLevel() == log.DebugLevel {
+				log.SetLevel(log.InfoLevel)
+			} else {
+				log.SetLevel(log.DebugLevel)
+			}
+			log.Info("log level changed", "level", log.GetLevel())
+		default:
+			m.viewport, cmd = m.viewport.Update(msg)
+			if key.Matches(msg, keys.Down, keys.PageDown) && m.viewport.AtBottom() {
+				m.follow = true
+			}
+			return m, cmd
 		}
+		return m, nil
 
 	case tea.WindowSizeMsg:
-		headerHeight := 15
+		m.help.Width = msg.Width
+		headerHeight := 23
 		footerHeight := 1
-		verticalMarginHeight := headerHeight + footerHeight
+		statusHeight := 1 // log pane status line
+		verticalMarginHeight := headerHeight + footerHeight + statusHeight
 
 		if !m.ready {
 			m.viewport = viewport.New(msg.Width, msg.Height-verticalMarginHeight)
 			m.viewport.Style = logStyle
+			m.viewport.KeyMap = keys.viewportKeys()
 			m.ready = true
 		} else {
 			m.viewport.Width = msg.Width
 			m.viewport.Height = msg.Height - verticalMarginHeight
 		}
 
-	case freqUpdateMsg:
-		m.freqValue = string(msg)
-
-	case freq5gUpdateMsg:
-		m.freq5GValue = string(msg)
-
-	case lastRebootTimeMsg:
-		m.lastRebootTime = string(msg)
-
-	case rxMsg:
-		rxBytes, err := strconv.Atoi(string(msg))
-		if err == nil {
-			m.rxBytes = rxBytes
-		}
-
-	case txMsg:
-		txBytes, err := strconv.Atoi(string(msg))
-		if err == nil {
-			m.txBytes = txBytes
-		}
-
-	case rsrqMsg:
-		rsrqValue, err := strconv.Atoi(string(msg))
-		if err == nil {
-			m.rsrqValue = rsrqValue
-		}
-
-	case rsrq5gMsg:
-		rsrq5GValue, err := strconv.Atoi(string(msg))
-		if err == nil {
-			m.rsrq5GValue = rsrq5GValue
-		}
-
-	case uptimeUpdateMsg:
-		uptimeValue, err := strconv.Atoi(string(msg))
-		if err == nil {
-			m.uptimeValue = uptimeValue
+	case sampleMsg:
+		r := m.router(msg.Router)
+		r.freqValue = msg.Freq
+		r.freq5GValue = msg.Freq5G
+		r.uptimeValue = msg.Uptime
+		r.rxBytes = msg.RxBytes
+		r.txBytes = msg.TxBytes
+		r.rsrqValue = msg.RSRQ
+		r.rsrq5GValue = msg.RSRQ5G
+		r.detail = msg.Detail
+		r.usage = msg.Usage
+		r.rates = msg.Rates
+		r.trend.Add(Sample(msg))
+
+	case eventMsg:
+		r := m.router(msg.Router)
+		switch msg.Kind {
+		case EventStateChanged:
+			r.state = msg.To
+		default:
+			r.recordEvent(msg.Kind, msg.Time)
 		}
 
 	case logMsg:
-		m.logs = append(m.logs, string(msg))
-		if len(m.logs) > maxLogs {
-			m.logs = m.logs[len(m.logs)-maxLogs:]
-		}
-		// Apply color formatting based on log level
-		for i, log := range m.logs {
-			switch {
-			case errorRegex.MatchString(log):
-				m.logs[i] = errorRegex.ReplaceAllString(log, errorStyle.Render("ERRO"))
-			case warnRegex.MatchString(log):
-				m.logs[i] = warnRegex.ReplaceAllString(log, warnStyle.Render("WARN"))
-			case infoRegex.MatchString(log):
-				m.logs[i] = infoRegex.ReplaceAllString(log, infoStyle.Render("INFO"))
-			case debugRegex.MatchString(log):
-				m.logs[i] = debugRegex.ReplaceAllString(log, debugStyle.Render("DEBU"))
-			case fatalRegex.MatchString(log):
-				m.logs[i] = fatalRegex.ReplaceAllString(log, fatalStyle.Render("FATA"))
-
-			}
-		}
-
-		m.viewport.SetContent(strings.Join(m.logs, "\n"))
-		m.viewport.GotoBottom()
+		m.addLog(newLogEntry(string(msg)))
 	}
 
 	m.viewport, cmd = m.viewport.Update(msg)
@@ -248,31 +275,79 @@ func (m model) View() string {
 		return "Initializing..."
 	}
 
+	headers := make([]string, len(m.routers))
+	for i, r := range m.routers {
+		headers[i] = r.header(len(m.routers) > 1 && i == m.selected)
+	}
+	header := lipgloss.JoinHorizontal(lipgloss.Top, headers...)
+
+	var body string
+	switch {
+	case m.confirm != nil:
+		body = m.confirmView()
+	case m.help.ShowAll:
+		body = logStyle.Render(titleStyle.Render("Keys") + " (press '?' to close)\n\n" + m.help.View(keys))
+	case m.showSettings:
+		body = m.settingsView()
+	case m.showDetail:
+		body = m.detailView()
+	case m.showNetwork:
+		body = m.networkView()
+	default:
+		body = m.logStatus() + "\n" + m.viewport.View()
+	}
+	footer := ""
+	if !m.help.ShowAll {
+		footer = logStyle.Render(m.help.View(keys))
+	}
+	return fmt.Sprintf("%s\n%s\n%s", header, lipgloss.PlaceVertical(m.viewport.Height+1, lipgloss.Top, body), footer)
+}
+
+// logPaneShown reports whether the log pane is on screen, so its keys
+// apply.
+func (m model) logPaneShown() bool {
+	return m.confirm == nil && !m.help.ShowAll && !m.showSettings && !m.showDetail && !m.showNetwork
+}
+
+// confirmView asks before a manual reboot, which drops the connection of
+// everyone on the router.
+func (m model) confirmView() string {
+	name := m.confirm.name
+	if name == "" {
+		name = "the router"
+	}
+	dialog := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("211")).Padding(1, 3).
+		Render(fmt.Sprintf("Reboot %s now?\n\n%s yes    %s no", titleStyle.Render(name), titleStyle.Render("y"), titleStyle.Render("n")))
+	return lipgloss.Place(m.viewport.Width, m.viewport.Height, lipgloss.Center, lipgloss.Center, dialog)
+}
+
+// header renders the status box of one router.
+func (r *routerView) header(selected bool) string {
 	// Header with FREQG value
 	var freqDisplay string
-	if m.freqValue == "" || m.freqValue == "NA" {
+	if r.freqValue == "" || r.freqValue == "NA" {
 		freqDisplay = textStyle.Background(lipgloss.Color("211")). // pink
 										Render("NA")
 	} else {
 		freqDisplay = textStyle.Foreground(lipgloss.Color("82")). // lime
-										Render(fmt.Sprintf("%7s", m.freqValue))
+										Render(fmt.Sprintf("%7s", r.freqValue))
 	}
 
 	// Header with FREQ_5G value
 	var freq5GDisplay string
-	if m.freq5GValue == "" || m.freq5GValue == "NA" {
+	if r.freq5GValue == "" || r.freq5GValue == "NA" {
 		freq5GDisplay = textStyle.Copy().
 			Background(lipgloss.Color("211")). // pink
 			Render("NA")
 	} else {
 		freq5GDisplay = textStyle.Foreground(lipgloss.Color("82")). // lime
-										Render(fmt.Sprintf("%7s", m.freq5GValue))
+										Render(fmt.Sprintf("%7s", r.freq5GValue))
 	}
 
 	// Header with Uptime value
 	uptimeDisplay := "0"
-	hh, mm, ss := secondsToTime(m.uptimeValue)
-	if m.uptimeValue < rebootWait {
+	hh, mm, ss := secondsToTime(r.uptimeValue)
+	if r.uptimeValue < int(r.policy.RebootWait.Seconds()) {
 		uptimeDisplay = textStyle.Foreground(lipgloss.Color("211")). // pink
 										Render(fmt.Sprintf("%d:%02d:%02d", hh, mm, ss))
 	} else {
@@ -280,386 +355,520 @@ func (m model) View() string {
 										Render(fmt.Sprintf("%d:%02d:%02d", hh, mm, ss))
 	}
 
-	rsrqDisplay := "0"
-
-	if m.rsrqValue < -15 {
-		rsrqDisplay = textStyle.Foreground(lipgloss.Color("#ff38c7")). // pink
-										Render(fmt.Sprintf("%3d ■□□□", m.rsrqValue))
-
-	} else if m.rsrqValue <= -10 {
-		rsrqDisplay = textStyle.Foreground(lipgloss.Color("#ffd438")). // yellow
-										Render(fmt.Sprintf("%3d ■■□□", m.rsrqValue))
-
-	} else if m.rsrqValue <= -5 {
-		rsrqDisplay = textStyle.Foreground(lipgloss.Color("#68e1fc")). // cyab
-										Render(fmt.Sprintf("%3d ■■■□", m.rsrqValue))
+	rsrqDisplay := signalDisplay(r.rsrqValue, r.policy.RSRQBands)
+	rsrq5GDisplay := signalDisplay(r.rsrq5GValue, r.policy.RSRQ5GBands)
 
+	// Header with Uptime value
+	rebootDisplay := "NONE"
+	if r.lastRebootTime != "NONE" {
+		rebootDisplay = textStyle.Foreground(lipgloss.Color("211")). // pink
+										Render(fmt.Sprintf("%ss", r.lastRebootTime))
 	} else {
-		rsrqDisplay = textStyle.Foreground(lipgloss.Color("#80fc68")). // line
-										Render(fmt.Sprintf("%3d ■■■■", m.rsrqValue))
-
+		rebootDisplay = textStyle.Foreground(lipgloss.Color("82")). // lime
+										Render("NONE")
 	}
 
-	rsrq5GDisplay := "0"
-
-	if m.rsrq5GValue <= -15 {
-		rsrq5GDisplay = textStyle.Foreground(lipgloss.Color("#ff38c7")). // pink
-											Render(fmt.Sprintf("%3d ■□□□", m.rsrq5GValue))
-
-	} else if m.rsrq5GValue <= -9 {
-		rsrq5GDisplay = textStyle.Foreground(lipgloss.Color("#ffd438")). // yellow
-											Render(fmt.Sprintf("%3d ■■□□", m.rsrq5GValue))
+	now := time.Now()
+	planDisplay := textStyle.Foreground(lipgloss.Color("82")).Render("NONE") // lime
+	if next := r.policy.RebootSchedule.Next(now); !next.IsZero() {
+		planDisplay = textStyle.Foreground(lipgloss.Color("82")).Render(next.Format("Mon 15:04")) // lime
+	}
+	if until := r.policy.QuietWindows.QuietUntil(now); !until.IsZero() {
+		planDisplay += textStyle.Foreground(lipgloss.Color("211")).Render(" QUIET→" + until.Format("15:04")) // pink
+	}
 
-	} else if m.rsrq5GValue <= -5 {
-		rsrq5GDisplay = textStyle.Foreground(lipgloss.Color("#68e1fc")). // cyab
-											Render(fmt.Sprintf("%3d ■■■□", m.rsrq5GValue))
+	stateDisplay := textStyle.Foreground(lipgloss.Color("82")).Render(r.state.String()) // lime
+	if r.state != StateHealthy {
+		stateDisplay = textStyle.Foreground(lipgloss.Color("211")).Render(r.state.String()) // pink
+	}
+	if r.monitor != nil && r.monitor.Paused() {
+		stateDisplay += textStyle.Foreground(lipgloss.Color("211")).Render(" PAUSED") // pink
+	}
 
-	} else {
-		rsrq5GDisplay = textStyle.Foreground(lipgloss.Color("#80fc68")). // line
-											Render(fmt.Sprintf("%3d ■■■■", m.rsrq5GValue))
+	dayAgo := now.Add(-24 * time.Hour)
+	dayDisplay := fmt.Sprintf("%d 5G drops, %d reboots", countSince(r.drops, dayAgo), countSince(r.reboots, dayAgo))
 
+	subtitle := "------------------"
+	if r.name != "" {
+		subtitle = r.name
 	}
-
-	// Header with Uptime value
-	rebootDisplay := "NONE"
-	if m.lastRebootTime != "NONE" {
-		rebootDisplay = textStyle.Foreground(lipgloss.Color("211")). // pink
-										Render(fmt.Sprintf("%ss", m.lastRebootTime))
-	} else {
-		rebootDisplay = textStyle.Foreground(lipgloss.Color("82")). // lime
-										Render("NONE")
+	if selected {
+		subtitle = "▸ " + subtitle + " ◂"
 	}
 
-	header := fmt.Sprintf("%s\n%s\n\n%s%s \t   %s%s \n%s%s \t  %s%s \n%s%8.2fMB \t %s%8.2fMB \n%s%s \n%s%s \n\n%s",
+	header := fmt.Sprintf("%s\n%s\n\n%s%s \t   %s%s \n%s%s \t  %s%s \n%s%8.2fMB \t %s%8.2fMB \n%s%s \n%s%s \n%s%s \n%s%s \n%s%s \n%s%s \n%s%s \n\n%s",
 		titleStyle.Width(32).Align(lipgloss.Center).Render("Vn007 Auto-Restart"),
-		titleStyle.Width(32).Align(lipgloss.Center).Render("------------------"),
+		titleStyle.Width(32).Align(lipgloss.Center).Render(subtitle),
 		titleStyle.Render("4G "), freqDisplay, titleStyle.Render("5G "), freq5GDisplay,
 		titleStyle.Render("ᯤ: "), rsrqDisplay, titleStyle.Render("ᯤ: "), rsrq5GDisplay,
-		titleStyle.Render("↑U"), float32(m.txBytes)*0.000001, titleStyle.Render("↓D"), float32(m.rxBytes)*0.000001,
+		titleStyle.Render("↑U"), float32(r.txBytes)*0.000001, titleStyle.Render("↓D"), float32(r.rxBytes)*0.000001,
+		titleStyle.Render("↑/s "), rateDisplay(r.rates.Up, r.rates.AvgUp, r.rates.PeakUp),
+		titleStyle.Render("↓/s "), rateDisplay(r.rates.Down, r.rates.AvgDown, r.rates.PeakDown),
 		titleStyle.Render("UPtime: "), uptimeDisplay,
 		titleStyle.Render("REboot: "), rebootDisplay,
-		titleStyle.Width(32).Align(lipgloss.Center).Render("press 'q' to stop."))
+		titleStyle.Render("PLanned:"), planDisplay,
+		titleStyle.Render("STate:  "), stateDisplay,
+		titleStyle.Render("24h:    "), dayDisplay,
+		r.trend.View())
 
-	header = headerStyle.Render(header)
-	// Viewport with logsq
-	return fmt.Sprintf("%s\n%s", header, m.viewport.View())
+	return headerStyle.Render(header)
 }
 
-func calculateBackoff(attempt int) time.Duration {
-	delay := baseDelay * time.Duration(1<<uint(attempt))
-	if delay > maxDelay {
-		delay = maxDelay
-	}
-	return delay
+// rateDisplay shows the current, average and peak rate of one direction.
+func rateDisplay(current, avg, peak float64) string {
+	return fmt.Sprintf("%s %s %s %s %s",
+		textStyle.Foreground(lipgloss.Color("82")).Render(fmt.Sprintf("%7s", formatRate(current))), // lime
+		titleStyle.Render("avg"), fmt.Sprintf("%6s", formatRate(avg)),
+		titleStyle.Render("pk"), fmt.Sprintf("%6s", formatRate(peak)))
 }
 
-func secondsToTime(seconds int) (hours, minutes, secs int) {
-	hours = seconds / 3600
-	minutes = (seconds % 3600) / 60
-	secs = seconds % 60
-	return
+// Signal bar colors, worst to best.
+var signalColors = [4]lipgloss.Color{
+	"#ff38c7", // pink
+	"#ffd438", // yellow
+	"#68e1fc", // cyan
+	"#80fc68", // lime
 }
 
-func monitorService(program *tea.Program, client *http.Client, url string) {
-
-	var uptime5g int
-	var bytes5G int
-	uptime5g = 0
-	bytes5G = 0
-
-	monitorPayload := MonitorPayload{
-		Cmd:       133,
-		Method:    "GET",
-		Language:  "EN",
-		SessionId: "",
-	}
-
-	loginPayload := LoginPayload{
-		Cmd:           100,
-		Method:        "POST",
-		SessionId:     "",
-		Username:      os.Getenv("UNICOM_USER"),
-		Passwd:        os.Getenv("PASSWORD_HASH"),
-		IsAutoUpgrade: "0",
-		Language:      "EN",
-	}
-
-	rebootPayload := RebootPayload{
-		Cmd:        6,
-		RebootType: 1,
-		Method:     "POST",
-		SessionId:  "",
-		Language:   "EN",
-	}
-
-	for {
-		responseData, err := sendRequestWithRetry(program, client, url, monitorPayload, "Monitoring")
-
-		if err != nil {
-			log.Error("monitoring cycle failed", "error", err, "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
-		}
+// signalDisplay renders an RSRQ value with its colored four-step bar.
+func signalDisplay(value int, bands Bands) string {
+	bars := bands.Bars(value)
+	return textStyle.Foreground(signalColors[bars-1]).
+		Render(fmt.Sprintf("%3d %s%s", value, strings.Repeat("■", bars), strings.Repeat("□", 4-bars)))
+}
 
-		if responseData.Uptime == nil {
-			log.Warn("uptime not found", "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
+// settingsView lists the active policy in place of the log pane.
+func (m model) settingsView() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Settings") + " (set with flags, env or config file, press 's' to close)\n")
+	for _, r := range m.routers {
+		b.WriteString("\n")
+		if r.name != "" {
+			b.WriteString(titleStyle.Render(r.name) + "\n")
 		}
-		uptimeStr := responseData.Uptime.(string)
-		uptime, err := strconv.Atoi(uptimeStr)
-		if err != nil {
-			log.Warn("uptime not found", "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
+		for _, row := range r.policy.Rows() {
+			fmt.Fprintf(&b, "%-16s %s\n", row[0], textStyle.Foreground(lipgloss.Color("82")).Render(row[1]))
 		}
-		program.Send(uptimeUpdateMsg(uptimeStr))
+	}
+	return logStyle.Render(b.String())
+}
 
-		if responseData.WAN_rX == nil {
-			log.Warn("WAN_rx not found", "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
+// detailView shows the full status of each router in place of the log
+// pane, LTE and NR side by side.
+func (m model) detailView() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Details") + " (press 'd' to close)\n")
+	for _, r := range m.routers {
+		d := r.detail
+		b.WriteString("\n")
+		if r.name != "" {
+			b.WriteString(titleStyle.Render(r.name) + "\n")
+		}
+		fmt.Fprintf(&b, "%-12s %s %s\n", "", titleStyle.Render(fmt.Sprintf("%-14s", "4G")), titleStyle.Render("5G"))
+		rows := [][3]string{
+			{"RSRP", detailInt(d.RSRP, "dBm"), detailInt(d.RSRP5G, "dBm")},
+			{"RSRQ", detailInt(&r.rsrqValue, "dB"), detailInt(&r.rsrq5GValue, "dB")},
+			{"SINR", detailInt(d.SINR, "dB"), detailInt(d.SINR5G, "dB")},
+			{"RSSI", detailInt(d.RSSI, "dBm"), "-"},
+			{"Band", detailString(d.Band), detailString(d.Band5G)},
+			{"Bandwidth", detailString(d.Bandwidth), detailString(d.Bandwidth5G)},
+			{"Channel", detailString(r.freqValue), detailString(r.freq5GValue)},
+			{"PCI", detailInt(d.PCI, ""), detailInt(d.PCI5G, "")},
+			{"Cell ID", detailString(d.CellID), detailString(d.CellID5G)},
+		}
+		for _, row := range rows {
+			fmt.Fprintf(&b, "%-12s %-14s %s\n", row[0], row[1], row[2])
+		}
+		fmt.Fprintf(&b, "%-12s %s\n", "Operator", detailString(d.Operator))
+		fmt.Fprintf(&b, "%-12s %s\n", "WAN IPv4", detailString(d.WanIPv4))
+		fmt.Fprintf(&b, "%-12s %s\n", "WAN IPv6", detailString(d.WanIPv6))
+		fmt.Fprintf(&b, "%-12s %s\n", "Temperature", detailInt(d.Temperature, "°C"))
+		u := r.usage
+		fmt.Fprintf(&b, "%-12s %-14s %s\n", "Used today", fmt.Sprintf("%.2f MB", float32(u.Day4G)*0.000001), fmt.Sprintf("%.2f MB", float32(u.Day5G)*0.000001))
+		fmt.Fprintf(&b, "%-12s %-14s %s\n", "Used month", fmt.Sprintf("%.2f MB", float32(u.Month4G)*0.000001), fmt.Sprintf("%.2f MB", float32(u.Month5G)*0.000001))
+		if u.Guard != "" {
+			fmt.Fprintf(&b, "%-12s %s\n", "4G budget", warnStyle.Render("limited ("+u.Guard+")"))
 		}
-		rxStr := responseData.WAN_rX.(string)
-		rx, err := strconv.Atoi(rxStr)
-		if err != nil {
-			log.Warn("WAN_rX not found", "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
-		}
-		program.Send(rxMsg(rxStr))
+	}
+	return logStyle.Render(b.String())
+}
 
-		if responseData.WAN_tX == nil {
-			log.Warn("WAN_tX not found", "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
-		}
-		txStr := responseData.WAN_tX.(string)
-		tx, err := strconv.Atoi(txStr)
-		if err != nil {
-			log.Warn("WAN_tX not found", "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
-		}
-		program.Send(txMsg(txStr))
+// networkMenu is what the network pane offers, applied to one router.
+var networkMenu = []struct {
+	label  string
+	change func(r *routerView) (RadioChange, bool)
+}{
+	{"Auto (4G and 5G)", setMode(vn007.NetworkModeAuto)},
+	{"4G only", setMode(vn007.NetworkMode4G)},
+	{"5G NSA only", setMode(vn007.NetworkMode5GNSA)},
+	{"5G SA only", setMode(vn007.NetworkMode5GSA)},
+	{"Lock NR to the current band", func(r *routerView) (RadioChange, bool) {
+		bands, err := vn007.ParseBands(r.detail.Band5G)
+		if err != nil || len(bands) != 1 {
+			return RadioChange{}, false
+		}
+		lock := r.monitor.Radio().Bands
+		lock.NR = bands
+		return RadioChange{Bands: &lock}, true
+	}},
+	{"Unlock all bands", func(*routerView) (RadioChange, bool) {
+		return RadioChange{Bands: &vn007.BandLock{}}, true
+	}},
+}
 
-		rsrq5tr := "0"
-		if responseData.RSRQ == nil {
-			log.Warn("RSRQ not found")
-		} else {
-			rsrq5tr = responseData.RSRQ.(string)
-			_, err = strconv.Atoi(rsrq5tr)
-			if err != nil {
-				log.Warn("RSRQ not found")
-			}
-		}
+func setMode(mode vn007.NetworkMode) func(*routerView) (RadioChange, bool) {
+	return func(*routerView) (RadioChange, bool) {
+		return RadioChange{Mode: mode}, true
+	}
+}
 
-		program.Send(rsrqMsg(rsrq5tr))
+// networkKey handles a key press in the network pane and reports whether
+// it was used.
+func (m *model) networkKey(msg tea.KeyMsg) bool {
+	switch {
+	case key.Matches(msg, keys.Up):
+		m.menuItem = (m.menuItem + len(networkMenu) - 1) % len(networkMenu)
+	case key.Matches(msg, keys.Down):
+		m.menuItem = (m.menuItem + 1) % len(networkMenu)
+	case key.Matches(msg, keys.Apply):
+		r := m.routers[m.selected]
+		item := networkMenu[m.menuItem]
+		change, ok := item.change(r)
+		if !ok {
+			log.Warn("no 5G band to lock to", "router", r.name)
+			return true
+		}
+		log.Info("changing radio settings", "router", r.name, "to", item.label)
+		r.monitor.RequestRadio(change)
+	default:
+		return false
+	}
+	return true
+}
 
-		rsrq5gStr := "0"
-		if responseData.RSRQ_5G == nil {
-			log.Warn("RSRQ 5G not found")
+// networkView shows the network mode and band locks of the routers and the
+// menu to change them, in place of the log pane.
+func (m model) networkView() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Network") + " (↑/↓ choose, enter apply, tab next router, press 'n' to close)\n")
+	for i, r := range m.routers {
+		radio := r.monitor.Radio()
+		mode, bands := "reading...", radio.Bands.String()
+		switch {
+		case !r.policy.Experimental:
+			mode, bands = "needs --experimental", "needs --experimental"
+		case radio.Mode != "":
+			mode = string(radio.Mode)
+		default:
+			bands = "reading..."
+		}
+		b.WriteString("\n")
+		name := r.name
+		if name == "" {
+			name = "Router"
+		}
+		if len(m.routers) > 1 && i == m.selected {
+			name = "▸ " + name
+		}
+		b.WriteString(titleStyle.Render(name) + "\n")
+		fmt.Fprintf(&b, "%-12s %s\n", "Mode", textStyle.Foreground(lipgloss.Color("82")).Render(mode))
+		fmt.Fprintf(&b, "%-12s %s\n", "Bands", textStyle.Foreground(lipgloss.Color("82")).Render(bands))
+	}
+	b.WriteString("\n")
+	for i, item := range networkMenu {
+		if i == m.menuItem {
+			b.WriteString(titleStyle.Render("> "+item.label) + "\n")
 		} else {
-			rsrq5gStr = responseData.RSRQ_5G.(string)
-			_, err = strconv.Atoi(rsrq5gStr)
-			if err != nil {
-				log.Warn("RSRQ 5G not found")
-			}
-
+			b.WriteString("  " + item.label + "\n")
 		}
+	}
+	return logStyle.Render(b.String())
+}
 
-		program.Send(rsrq5gMsg(rsrq5gStr))
+// detailInt formats a reported number with its unit, or "-" when the
+// router left it out.
+func detailInt(v *int, unit string) string {
+	if v == nil {
+		return "-"
+	}
+	return strings.TrimSpace(fmt.Sprintf("%d %s", *v, unit))
+}
 
-		log.Debug("Total traffic", "MB", float32(tx+rx)*0.000001)
+func detailString(v string) string {
+	if v == "" || v == "NA" {
+		return "-"
+	}
+	return v
+}
 
-		if responseData.FREQ == nil {
-			program.Send(freqUpdateMsg("NA"))
-			log.Debug("No Data Connection", "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
-		}
-		_, fqerr := strconv.Atoi(responseData.FREQ.(string))
-		if fqerr != nil {
-			program.Send(freqUpdateMsg("NA"))
-			log.Debug("No Data Connection", "sleep", baseDelay)
-			time.Sleep(baseDelay)
-			continue
-		}
-		program.Send(freqUpdateMsg(responseData.FREQ.(string)))
-		log.Debug("4G available", "FREQ", responseData.FREQ.(string))
-
-		// The most important check
-		if responseData.FREQ_5G != nil {
-			_, fqerr := strconv.Atoi(responseData.FREQ_5G.(string))
-			if fqerr == nil {
-				program.Send(freq5gUpdateMsg(responseData.FREQ_5G.(string)))
-				log.Debug("5G available", "FREQ_5G", responseData.FREQ_5G.(string))
-				uptime5g = uptime
-				bytes5G = tx + rx
-				time.Sleep(baseDelay)
-				continue
-			}
+// loadHistory seeds the headers from the history file so reboot and drop
+// counts survive restarts.
+func (m *model) loadHistory(history *HistoryStore) {
+	entries, err := history.Entries(time.Time{})
+	if err != nil {
+		log.Warn("error reading history", "error", err)
+		return
+	}
+	for _, r := range m.routers {
+		for _, e := range filterRouter(entries, r.name) {
+			r.recordEvent(e.Kind, e.Time)
 		}
+	}
+}
 
-		program.Send(freq5gUpdateMsg("NA"))
+func (r *routerView) recordEvent(kind EventKind, at time.Time) {
+	dayAgo := at.Add(-24 * time.Hour)
+	switch kind {
+	case Event5GLost:
+		r.drops = append(pruneBefore(r.drops, dayAgo), at)
+	case EventReboot:
+		r.reboots = append(pruneBefore(r.reboots, dayAgo), at)
+		r.lastRebootTime = at.Local().Format("January 2, 2006 3:04:05 PM")
+	}
+}
 
-		if uptime5g == 0 {
-			uptime5g = uptime
-		}
+// pruneBefore drops the leading times earlier than t.
+func pruneBefore(times []time.Time, t time.Time) []time.Time {
+	for len(times) > 0 && times[0].Before(t) {
+		times = times[1:]
+	}
+	return times
+}
 
-		if bytes5G == 0 {
-			bytes5G = tx + rx
-		}
+func countSince(times []time.Time, t time.Time) int {
+	return len(pruneBefore(times, t))
+}
 
-		timediff := uptime - uptime5g
-		bytesdiff := tx + rx - bytes5G
+func secondsToTime(seconds int) (hours, minutes, secs int) {
+	hours = seconds / 3600
+	minutes = (seconds % 3600) / 60
+	secs = seconds % 60
+	return
+}
 
-		if (timediff < recoverTime) && bytesdiff < recoverBytes {
-			log.Warn("5G recovery", "downtime(sec)", timediff)
-			log.Warn("4G data used", "MB", float32(bytesdiff)*0.000001)
-			//no delay
-			continue
-		}
+// subcommands run instead of the monitor when named as the first argument.
+var subcommands = map[string]func(args []string) error{
+	"history": func(args []string) error { return runHistoryCommand(args, os.Stdout) },
+	"hash-password": func(args []string) error {
+		return runHashPasswordCommand(args, os.Stdin, os.Stdout)
+	},
+	"creds": func(args []string) error {
+		return runCredsCommand(args, os.Getenv, os.Stdin, os.Stdout)
+	},
+}
 
-		log.Warn("FREQ_5G not present, initiating reboot")
+func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				if !errors.Is(err, flag.ErrHelp) {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+				}
+				os.Exit(2)
+			}
+			return
+		}
+	}
 
-		responseData, err = sendRequestWithRetry(program, client, url, loginPayload, "Login")
+	headless := flag.Bool("headless", false, "run without the TUI and write logs to stdout or --log-file")
+	logFile := flag.String("log-file", "", "headless mode: append logs to this file instead of stdout")
+	logFormat := flag.String("log-format", "logfmt", "headless mode: log format, one of text, logfmt or json")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9100")
+	apiAddr := flag.String("api-addr", "", "serve the control API on this address, e.g. :8007 (needs API_TOKEN)")
+	historyPath := flag.String("history", defaultHistoryPath(), "append reboots, 5G losses and login failures to this file")
+	usagePath := flag.String("usage", defaultUsagePath(), "keep daily and monthly 4G and 5G traffic in this file")
+
+	record := flag.String("record", "", "append every router request and reply to this JSONL file, secrets redacted")
+	replay := flag.String("replay", "", "answer router requests from a file written by --record instead of the router")
+
+	vaultPath := flag.String("vault", defaultVaultPath(), "encrypted credentials file managed with 'vn007go creds'")
+	configPath := flag.String("config", defaultConfigPath(), "YAML config file with router profiles")
+	profile := flag.String("profile", "", "comma-separated router profiles from the config file to monitor, or all (default: default_profile, else all)")
+	policyFlags := RegisterPolicyFlags(flag.CommandLine)
+	flag.Parse()
+	if *record != "" && *replay != "" {
+		log.Fatal("--record and --replay cannot be combined")
+	}
 
-		if (err != nil) || (!responseData.Success) {
-			log.Warn("login failed", "error", err, "sleep", baseDelay)
-			time.Sleep(180)
-			continue
+	// A missing .env is fine when everything comes from the config file or
+	// the environment.
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal("Error loading .env file", "error", err)
+	}
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal("Error loading config", "error", err)
+	}
+	routers, err := config.Resolve(*profile, dotenv, os.Getenv, policyFlags)
+	if err != nil {
+		log.Fatal("Invalid settings", "error", err)
+	}
+	// Credentials in the vault take precedence over plaintext files.
+	if vaultExists(*vaultPath) {
+		passphrase, err := vaultPassphrase(os.Getenv, os.Stdin, os.Stderr)
+		if err != nil {
+			log.Fatal("Error reading vault passphrase", "error", err)
 		}
-
-		rebootPayload.SessionId = responseData.SessionId.(string)
-		_, err = sendRequestWithRetry(program, client, url, rebootPayload, "Reboot")
+		vault, err := OpenVault(*vaultPath, passphrase)
 		if err != nil {
-			log.Error("reboot sequence failed", "error", err, "sleep", rebootSleep)
-			time.Sleep(120 * time.Second)
-			continue
+			log.Fatal("Error opening vault", "error", err)
 		}
-
-		program.Send(lastRebootTimeMsg(time.Now().Format("January 2, 2006 3:04:05 PM")))
-		log.Info("reboot sequence completed", "sleep", rebootSleep)
-		time.Sleep(rebootSleep)
-		uptime5g = 0
+		applyVault(vault, routers)
 	}
-}
+	// The token and debug switch are not per router, so any profile will do.
+	settings := routers[0]
+	setLogLevel(settings.Debug)
 
-func sendRequestWithRetry(program *tea.Program, client *http.Client, url string, payload interface{}, reqType string) (*ResponseData, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		jsonData, err := json.Marshal(payload)
+	if *replay != "" {
+		explicit := map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		dir, err := replayStores(explicit, historyPath, usagePath)
 		if err != nil {
-			return nil, fmt.Errorf("error marshaling JSON: %v", err)
+			log.Fatal("Error preparing replay", "error", err)
 		}
+		defer os.RemoveAll(dir)
+	}
+	history, err := OpenHistory(*historyPath)
+	if err != nil {
+		log.Fatal("Error opening history", "error", err)
+	}
+	defer history.Close()
+	usage, err := OpenUsage(*usagePath)
+	if err != nil {
+		log.Fatal("Error opening usage", "error", err)
+	}
 
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, fmt.Errorf("error creating request: %v", err)
+	// Each router gets its own client, so sessions are never shared.
+	var monitors []*Monitor
+	for _, r := range routers {
+		client := vn007.NewClient(vn007.Endpoint(r.IP), r.User, r.PasswordHash)
+		monitor := NewMonitor(client, r.Policy, realClock{}, history)
+		monitor.SetName(r.Profile)
+		if err := monitor.SetUsageStore(usage); err != nil {
+			log.Fatal("Error loading usage", "error", err)
 		}
+		monitors = append(monitors, monitor)
+	}
 
-		req.Header.Set("Content-Type", "application/json")
-
-		// log.Debug(fmt.Sprintf("REQ <<< %s", jsonData))
-
-		resp, err := client.Do(req)
+	if *record != "" {
+		f, err := recordTo(*record, monitors)
 		if err != nil {
-			lastErr = err
-			delay := calculateBackoff(attempt)
-			log.Error("request failed", "type", reqType, "attempt", attempt+1, "error", err)
-			time.Sleep(delay)
-			continue
+			log.Fatal("Error recording", "error", err)
 		}
-
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		defer f.Close()
+	}
+	if *replay != "" {
+		done, err := replayFrom(*replay, monitors)
 		if err != nil {
-			lastErr = err
-			delay := calculateBackoff(attempt)
-			log.Error("failed to read response", "type", reqType, "attempt", attempt+1, "error", err)
-			time.Sleep(delay)
-			continue
-		}
-
-		var responseData ResponseData
-		// log.Debug(fmt.Sprintf("RESP >>> %s", body))
+			log.Fatal("Error loading replay", "error", err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-done:
+				log.Info("replay finished", "file", *replay)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
 
-		if reqType == "Reboot" && resp.StatusCode == 200 {
-			log.Debug("request successful", "type", reqType, "attempt", attempt+1)
-			responseData.Success = true
-			responseData.FREQ_5G = "-"
-			responseData.Uptime = 0
-			return &responseData, nil
+	if *metricsAddr != "" {
+		metrics := NewMetrics()
+		for _, monitor := range monitors {
+			monitor.client.OnRetry = metrics.RetryFor(monitor.Name())
+			monitor.AddSink(metrics)
 		}
 
-		err = json.Unmarshal(body, &responseData)
-		if err != nil {
-			lastErr = err
-			delay := calculateBackoff(attempt)
-			log.Error("invalid JSON response", "type", reqType, "attempt", attempt+1, "error", err)
-			time.Sleep(delay)
-			continue
-		}
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics)
+		go func() {
+			if err := serve(ctx, *metricsAddr, mux); err != nil {
+				log.Error("metrics listener failed", "addr", *metricsAddr, "error", err)
+			}
+		}()
+	}
 
-		if reqType == "Login" && responseData.SessionId == nil {
-			log.Debug("authentication failed", "type", reqType, "attempt", attempt+1)
-			responseData.Success = false
-			return &responseData, nil
+	if *apiAddr != "" {
+		if settings.APIToken == "" {
+			log.Fatal("API_TOKEN or api_token must be set to use --api-addr")
 		}
+		api := NewAPI(history, settings.APIToken, monitors...)
+		go func() {
+			if err := serve(ctx, *apiAddr, api.Handler()); err != nil {
+				log.Error("API listener failed", "addr", *apiAddr, "error", err)
+			}
+		}()
+	}
 
-		if responseData.Success {
-			log.Debug("request successful", "type", reqType, "attempt", attempt+1)
-			return &responseData, nil
+	if *headless {
+		if err := runHeadless(ctx, monitors, *logFile, *logFormat); err != nil {
+			fmt.Fprintln(os.Stderr, "Error running headless:", err)
+			os.Exit(1)
 		}
-
-		lastErr = fmt.Errorf("request failed with success=false")
-		delay := calculateBackoff(attempt)
-		log.Error("request unsuccessful", "type", reqType, "attempt", attempt+1)
-		time.Sleep(delay)
+		return
 	}
 
-	return nil, fmt.Errorf("max retries (%d) exceeded with error: %v", maxRetries, lastErr)
+	runTUI(ctx, monitors, history)
 }
 
-func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+func setLogLevel(debug bool) {
+	if debug {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		log.SetLevel(log.InfoLevel)
 	}
+}
+
+func runTUI(ctx context.Context, monitors []*Monitor, history *HistoryStore) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// Initial model
 	m := model{
-		logs:           make([]string, 0, maxLogs),
-		freq5GValue:    "NA",
-		uptimeValue:    0,
-		lastRebootTime: "NONE",
+		minLevel: log.DebugLevel,
+		search:   newLogSearch(),
+		follow:   true,
+		help:     help.New(),
+	}
+	for _, monitor := range monitors {
+		m.routers = append(m.routers, newRouterView(monitor))
 	}
+	m.loadHistory(history)
 
 	// Initialize the program
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx))
 
 	// Configure custom log writer
-	log.SetOutput(logWriter{program: p})
-	if os.Getenv("DEBUG") == "Yes" {
-		log.SetLevel(log.DebugLevel)
-	} else {
-		log.SetLevel(log.InfoLevel & log.WarnLevel & log.FatalLevel)
-	}
+	log.SetOutput(&logWriter{program: p})
+	log.SetFormatter(log.JSONFormatter) // parsed back into entries by the log pane
 	log.SetReportCaller(false)
 	log.SetTimeFormat("15:04:05")
 
-	// Start monitoring service in a goroutine
-	client := &http.Client{Timeout: 10 * time.Second}
-	//url := "http://192.168.0.1/cgi-bin/http.cgi"
-	url := fmt.Sprintf("http://%s/cgi-bin/http.cgi", os.Getenv("IP"))
-
-	go monitorService(p, client, url)
+	// Start one monitoring goroutine per router
+	done := make(chan struct{}, len(monitors))
+	for _, monitor := range monitors {
+		monitor.AddSink(tuiSink{program: p})
+		go func() {
+			monitor.Run(ctx)
+			done <- struct{}{}
+		}()
+	}
 
 	// Run the program
-	if _, err := p.Run(); err != nil {
+	if _, err := p.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
 		fmt.Println("Error running program:", err)
 	}
+
+	// Let the monitors log out and save usage before exiting.
+	cancel()
+	for range monitors {
+		<-done
+	}
 }
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics collects router telemetry and monitor counters and serves them
+// in the Prometheus text exposition format. With several routers every
+// series carries a router label.
+type Metrics struct {
+	mu      sync.Mutex
+	routers map[string]*routerMetrics
+}
+
+type routerMetrics struct {
+	name          string
+	sample        Sample
+	haveSample    bool
+	state         State
+	reboots       int
+	fiveGLost     int
+	loginFailures int
+	unavailable   int // Event5GUnavailable alerts
+	retries       map[string]int
+	actions       map[string]int // recovery steps by action
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{routers: map[string]*routerMetrics{}}
+}
+
+// router returns the series of one router, creating them on first use.
+// The caller holds m.mu.
+func (m *Metrics) router(name string) *routerMetrics {
+	r, ok := m.routers[name]
+	if !ok {
+		r = &routerMetrics{name: name, retries: map[string]int{}, actions: map[string]int{}}
+		m.routers[name] = r
+	}
+	return r
+}
+
+func (m *Metrics) Sample(sample Sample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.router(sample.Router)
+	r.sample = sample
+	r.haveSample = true
+}
+
+func (m *Metrics) Event(ev Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.router(ev.Router)
+	switch ev.Kind {
+	case EventStateChanged:
+		r.state = ev.To
+	case Event5GLost:
+		r.fiveGLost++
+	case EventReboot:
+		r.reboots++
+	case EventLoginFailed:
+		r.loginFailures++
+	case Event5GUnavailable:
+		r.unavailable++
+	case EventRecoveryAction:
+		r.actions[ev.Cause]++
+	}
+}
+
+// Retry counts a retried router request. It matches vn007.Client.OnRetry.
+func (m *Metrics) Retry(reqType string, attempt int, err error) {
+	m.RetryFor("")(reqType, attempt, err)
+}
+
+// RetryFor returns a vn007.Client.OnRetry hook counting retries for the
+// named router.
+func (m *Metrics) RetryFor(router string) func(reqType string, attempt int, err error) {
+	return func(reqType string, _ int, _ error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.router(router).retries[reqType]++
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes all metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var all, sampled []*routerMetrics
+	if len(m.routers) == 0 {
+		// Zero counters before anything happened, without storing them.
+		all = append(all, &routerMetrics{retries: map[string]int{}, actions: map[string]int{}})
+	}
+	for _, r := range m.routers {
+		all = append(all, r)
+		if r.haveSample {
+			sampled = append(sampled, r)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	sort.Slice(sampled, func(i, j int) bool { return sampled[i].name < sampled[j].name })
+
+	p := &promWriter{w: w}
+
+	if len(sampled) > 0 {
+		p.metric("vn007_uptime_seconds", "gauge", "Router uptime.")
+		for _, r := range sampled {
+			p.value(r.labels(), r.sample.Uptime)
+		}
+		p.metric("vn007_wan_receive_bytes_total", "counter", "Bytes received on the WAN interface since the router started.")
+		for _, r := range sampled {
+			p.value(r.labels(), r.sample.RxBytes)
+		}
+		p.metric("vn007_wan_transmit_bytes_total", "counter", "Bytes sent on the WAN interface since the router started.")
+		for _, r := range sampled {
+			p.value(r.labels(), r.sample.TxBytes)
+		}
+		p.metric("vn007_wan_rate_bytes_per_second", "gauge", "WAN transfer rate: the latest reading, the average since start and the peak.")
+		for _, r := range sampled {
+			rates := r.sample.Rates
+			for _, v := range []struct {
+				direction, stat string
+				value           float64
+			}{
+				{"up", "current", rates.Up}, {"up", "avg", rates.AvgUp}, {"up", "peak", rates.PeakUp},
+				{"down", "current", rates.Down}, {"down", "avg", rates.AvgDown}, {"down", "peak", rates.PeakDown},
+			} {
+				p.value(r.labels("direction", v.direction, "stat", v.stat), int(v.value))
+			}
+		}
+		p.metric("vn007_rsrq_db", "gauge", "Reference signal received quality.")
+		for _, r := range sampled {
+			p.value(r.labels("rat", "4g"), r.sample.RSRQ)
+			p.value(r.labels("rat", "5g"), r.sample.RSRQ5G)
+		}
+		p.metric("vn007_arfcn", "gauge", "Channel number of the serving cell, absent without a connection.")
+		for _, r := range sampled {
+			if freq, err := strconv.Atoi(r.sample.Freq); err == nil {
+				p.value(r.labels("rat", "4g"), freq)
+			}
+			if freq, err := strconv.Atoi(r.sample.Freq5G); err == nil {
+				p.value(r.labels("rat", "5g"), freq)
+			}
+		}
+		p.metric("vn007_usage_bytes", "gauge", "WAN traffic so far in the current day or month, by radio.")
+		for _, r := range sampled {
+			u := r.sample.Usage
+			p.value(r.labels("rat", "4g", "period", "day"), u.Day4G)
+			p.value(r.labels("rat", "5g", "period", "day"), u.Day5G)
+			p.value(r.labels("rat", "4g", "period", "month"), u.Month4G)
+			p.value(r.labels("rat", "5g", "period", "month"), u.Month5G)
+		}
+		p.metric("vn007_budget_limited", "gauge", "Whether a 4G budget action is in force.")
+		for _, r := range sampled {
+			p.value(r.labels(), boolValue(r.sample.Usage.Guard != ""))
+		}
+		p.metric("vn007_5g_available", "gauge", "Whether the router reports a 5G frequency.")
+		for _, r := range sampled {
+			p.value(r.labels(), boolValue(r.sample.Freq5G != "NA"))
+		}
+	}
+
+	p.metric("vn007_monitor_state", "gauge", "Current monitor state.")
+	for _, r := range all {
+		for s := StateHealthy; s <= StateUnavailable; s++ {
+			p.value(r.labels("state", s.String()), boolValue(r.state == s))
+		}
+	}
+
+	p.metric("vn007_reboots_total", "counter", "Reboots sent by the monitor.")
+	for _, r := range all {
+		p.value(r.labels(), r.reboots)
+	}
+	p.metric("vn007_5g_loss_total", "counter", "Times 5G was lost.")
+	for _, r := range all {
+		p.value(r.labels(), r.fiveGLost)
+	}
+	p.metric("vn007_login_failures_total", "counter", "Failed logins.")
+	for _, r := range all {
+		p.value(r.labels(), r.loginFailures)
+	}
+	p.metric("vn007_5g_unavailable_total", "counter", "Outages in which the reboot caps were reached.")
+	for _, r := range all {
+		p.value(r.labels(), r.unavailable)
+	}
+
+	p.metric("vn007_recovery_actions_total", "counter", "Recovery steps short of a reboot, by action.")
+	for _, r := range all {
+		for _, action := range []string{actionReattach, actionData, actionBandLock} {
+			p.value(r.labels("action", action), r.actions[action])
+		}
+	}
+
+	p.metric("vn007_request_retries_total", "counter", "Router requests retried after a failure.")
+	for _, r := range all {
+		types := make([]string, 0, len(r.retries))
+		for reqType := range r.retries {
+			types = append(types, reqType)
+		}
+		sort.Strings(types)
+		for _, reqType := range types {
+			p.value(r.labels("type", reqType), r.retries[reqType])
+		}
+	}
+
+	return p.n, p.err
+}
+
+// labels formats the label set of a series, leading with router="name"
+// unless the router is unnamed.
+func (r *routerMetrics) labels(kv ...string) string {
+	var parts []string
+	if r.name != "" {
+		parts = append(parts, fmt.Sprintf("router=%q", r.name))
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", kv[i], kv[i+1]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// promWriter writes exposition lines, remembering the first error.
+type promWriter struct {
+	w    io.Writer
+	name string
+	n    int64
+	err  error
+}
+
+func (p *promWriter) metric(name, kind, help string) {
+	p.name = name
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (p *promWriter) value(labels string, v int) {
+	if labels != "" {
+		p.printf("%s{%s} %d\n", p.name, labels, v)
+	} else {
+		p.printf("%s %d\n", p.name, v)
+	}
+}
+
+func (p *promWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.n += int64(n)
+	p.err = err
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// serve runs an HTTP listener on addr until ctx is cancelled.
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestMetrics_Exposition(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+	metrics := NewMetrics()
+	m.AddSink(metrics)
+	m.client.OnRetry = metrics.Retry
+
+	router.Advance(0, 2000, 1000)
+	router.Fail(vn007test.FaultMalformedJSON)
+	step(t, m, clock)
+	router.Drop5G()
+	step(t, m, clock)
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock)
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE vn007_uptime_seconds gauge\nvn007_uptime_seconds 605\n",
+		"vn007_wan_receive_bytes_total 2000\n",
+		`vn007_rsrq_db{rat="4g"} -10`,
+		`vn007_arfcn{rat="4g"} 1850`,
+		"vn007_5g_available 0\n",
+		`vn007_wan_rate_bytes_per_second{direction="down",stat="peak"} 0`,
+		`vn007_monitor_state{state="CoolingDown"} 1`,
+		`vn007_monitor_state{state="Healthy"} 0`,
+		"vn007_reboots_total 1\n",
+		"vn007_5g_loss_total 1\n",
+		"vn007_login_failures_total 0\n",
+		`vn007_request_retries_total{type="Monitoring"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetrics_NoSampleYet(t *testing.T) {
+	var b strings.Builder
+	metrics := NewMetrics()
+	metrics.WriteTo(&b)
+
+	if strings.Contains(b.String(), "vn007_uptime_seconds") {
+		t.Errorf("uptime exported before the first sample:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), "vn007_reboots_total 0\n") {
+		t.Errorf("reboot counter missing:\n%s", b.String())
+	}
+
+	b.Reset()
+	metrics.Sample(Sample{Router: "home", Freq: "1850", Freq5G: "NA"})
+	metrics.WriteTo(&b)
+	if strings.Contains(b.String(), "vn007_reboots_total 0\n") {
+		t.Errorf("unnamed series left behind by an early scrape:\n%s", b.String())
+	}
+}
+
+func TestMetrics_RouterLabels(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Sample(Sample{Router: "office", Uptime: 30, Freq: "1850", Freq5G: "NA"})
+	metrics.Sample(Sample{Router: "home", Uptime: 600, Freq: "1850", Freq5G: "627264"})
+	metrics.Event(Event{Router: "office", Kind: EventReboot})
+	metrics.RetryFor("home")("Monitoring", 1, nil)
+
+	var b strings.Builder
+	metrics.WriteTo(&b)
+	body := b.String()
+
+	for _, want := range []string{
+		"vn007_uptime_seconds{router=\"home\"} 600\nvn007_uptime_seconds{router=\"office\"} 30\n",
+		`vn007_5g_available{router="office"} 0`,
+		`vn007_rsrq_db{router="home",rat="5g"} 0`,
+		`vn007_reboots_total{router="office"} 1`,
+		`vn007_reboots_total{router="home"} 0`,
+		`vn007_request_retries_total{router="home",type="Monitoring"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Count(body, "# TYPE vn007_uptime_seconds") != 1 {
+		t.Errorf("metric family repeated per router:\n%s", body)
+	}
+}
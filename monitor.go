@@ -0,0 +1,706 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+const (
+	loginRetryDelay  = 180 * time.Second // wait after a failed login before trying again
+	rebootRetryDelay = 120 * time.Second // wait after a failed reboot command
+)
+
+// State is where the monitor is in the 5G-loss / reboot cycle.
+type State int
+
+const (
+	StateHealthy     State = iota // 5G is up
+	State5GLost                   // 5G just disappeared
+	StateRecovering               // waiting to see if 5G comes back by itself
+	StateRebooting                // logging in and sending the reboot command
+	StateCoolingDown              // waiting for the router to come back up
+	StateUnavailable              // reboot cap reached, waiting for 5G without rebooting
+)
+
+func (s State) String() string {
+	switch s {
+	case StateHealthy:
+		return "Healthy"
+	case State5GLost:
+		return "5GLost"
+	case StateRecovering:
+		return "Recovering"
+	case StateRebooting:
+		return "Rebooting"
+	case StateCoolingDown:
+		return "CoolingDown"
+	case StateUnavailable:
+		return "Unavailable"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	for st := StateHealthy; st <= StateUnavailable; st++ {
+		if st.String() == string(text) {
+			*s = st
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown state %q", text)
+}
+
+// Clock abstracts time so the monitor can be driven deterministically.
+type Clock interface {
+	Now() time.Time
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Sample is the telemetry read from one status poll.
+type Sample struct {
+	Router  string     `json:"router,omitempty"` // monitor name, empty with a single unnamed router
+	Uptime  int        `json:"uptime"`
+	RxBytes int        `json:"wan_rx_bytes"`
+	TxBytes int        `json:"wan_tx_bytes"`
+	RSRQ    int        `json:"rsrq"`
+	RSRQ5G  int        `json:"rsrq_5g"`
+	Freq    string     `json:"freq"`    // "NA" without a data connection
+	Freq5G  string     `json:"freq_5g"` // "NA" without 5G
+	Detail  Detail     `json:"detail"`
+	Usage   Usage      `json:"usage"` // traffic so far today and this month
+	Rates   Throughput `json:"rates"` // WAN bytes per second
+}
+
+// Detail is the rest of the status reply: radio measurements, cell
+// identity, addressing and modem health. Values the router did not report
+// are nil or empty.
+type Detail struct {
+	RSRP        *int   `json:"rsrp,omitempty"`
+	RSRP5G      *int   `json:"rsrp_5g,omitempty"`
+	SINR        *int   `json:"sinr,omitempty"`
+	SINR5G      *int   `json:"sinr_5g,omitempty"`
+	RSSI        *int   `json:"rssi,omitempty"`
+	PCI         *int   `json:"pci,omitempty"`
+	PCI5G       *int   `json:"pci_5g,omitempty"`
+	CellID      string `json:"cell_id,omitempty"`
+	CellID5G    string `json:"cell_id_5g,omitempty"`
+	Band        string `json:"band,omitempty"`
+	Band5G      string `json:"band_5g,omitempty"`
+	Bandwidth   string `json:"bandwidth,omitempty"`
+	Bandwidth5G string `json:"bandwidth_5g,omitempty"`
+	Operator    string `json:"operator,omitempty"`
+	WanIPv4     string `json:"wan_ipv4,omitempty"`
+	WanIPv6     string `json:"wan_ipv6,omitempty"`
+	Temperature *int   `json:"temperature,omitempty"`
+}
+
+func readDetail(status *vn007.Status) Detail {
+	intField := func(key string, v int) *int {
+		if !status.Has(key) {
+			return nil
+		}
+		return &v
+	}
+	return Detail{
+		RSRP:        intField("RSRP", status.RSRP),
+		RSRP5G:      intField("RSRP_5G", status.RSRP5G),
+		SINR:        intField("SINR", status.SINR),
+		SINR5G:      intField("SINR_5G", status.SINR5G),
+		RSSI:        intField("RSSI", status.RSSI),
+		PCI:         intField("PCI", status.PCI),
+		PCI5G:       intField("PCI_5G", status.PCI5G),
+		CellID:      status.CellID,
+		CellID5G:    status.CellID5G,
+		Band:        status.Band,
+		Band5G:      status.Band5G,
+		Bandwidth:   status.Bandwidth,
+		Bandwidth5G: status.Bandwidth5G,
+		Operator:    status.Operator,
+		WanIPv4:     status.WanIPv4,
+		WanIPv6:     status.WanIPv6,
+		Temperature: intField("temperature", status.Temperature),
+	}
+}
+
+// EventKind names something noteworthy the monitor did or saw.
+type EventKind string
+
+const (
+	EventStateChanged EventKind = "state"
+	Event5GLost       EventKind = "5g_lost"
+	Event5GRecovered  EventKind = "5g_recovered"
+	EventReboot       EventKind = "reboot"
+	EventLoginFailed  EventKind = "login_failed"
+	// EventRecoveryAction is a recovery step short of a reboot; Cause names
+	// the action.
+	EventRecoveryAction EventKind = "recovery"
+	// EventBudget is a budget action being applied or lifted; Cause says
+	// which.
+	EventBudget EventKind = "budget"
+	// Event5GUnavailable is the alert raised once per outage when the reboot
+	// caps are reached.
+	Event5GUnavailable EventKind = "5g_unavailable"
+)
+
+// Event is reported to the sink on every transition and notable action.
+type Event struct {
+	Router  string
+	Time    time.Time
+	Kind    EventKind
+	From    State
+	To      State
+	Cause   string
+	Sample  Sample
+	Bytes4G int // bytes used since 5G was last seen
+	Err     error
+}
+
+// Sink receives everything the monitor observes.
+type Sink interface {
+	Sample(Sample)
+	Event(Event)
+}
+
+// multiSink fans monitor output out to several sinks.
+type multiSink []Sink
+
+func (s multiSink) Sample(sample Sample) {
+	for _, sink := range s {
+		sink.Sample(sample)
+	}
+}
+
+func (s multiSink) Event(ev Event) {
+	for _, sink := range s {
+		sink.Event(ev)
+	}
+}
+
+// Monitor watches one router and reboots it when 5G stays down.
+type Monitor struct {
+	name    string
+	client  *vn007.Client
+	session *vn007.Session
+	policy  Policy
+	clock   Clock
+	sinks   multiSink
+	wake    chan struct{}
+
+	mu              sync.Mutex
+	state           State
+	sample          Sample
+	paused          bool
+	rebootRequested bool
+	lastReboot      time.Time
+	nextScheduled   time.Time // next planned reboot, zero without a schedule
+
+	lastSeen5G    int  // uptime when 5G was last seen
+	bytesAt5G     int  // WAN byte total when 5G was last seen
+	baseline      bool // whether lastSeen5G and bytesAt5G are set
+	cooldownUntil time.Time
+
+	rebootTimes []time.Time // reboots of the past day, oldest first
+	unrecovered int         // recovery reboots since 5G was last seen
+	outage      bool        // whether 5G is gone, across any reboots since
+	alerted     bool        // whether this outage raised Event5GUnavailable
+	holdNote    string      // why reboots are held back, as last logged
+	nextStep    int         // index of the next recovery step to take
+	stepUntil   time.Time   // when the last recovery step has had its chance
+
+	usage      Usage
+	usageStore *UsageStore
+	usageSaved time.Time
+	lastTotal  int  // WAN byte total at the previous sample
+	haveTotal  bool // whether lastTotal is set
+	guardRetry time.Time
+
+	radio         Radio
+	radioStale    bool // whether radio needs reading
+	radioChanges  []RadioChange
+	goodNRBand    int             // NR band 5G was last seen on
+	bandRestore   *vn007.BandLock // lock to put back, nil unless lockGoodBand is in force
+	bandLockUntil time.Time
+
+	rates rateMeter
+}
+
+func NewMonitor(client *vn007.Client, policy Policy, clock Clock, sinks ...Sink) *Monitor {
+	client.Retry = policy.Retry()
+	session := vn007.NewSession(client)
+	session.Now = clock.Now
+	return &Monitor{
+		client:  client,
+		session: session,
+		policy:  policy,
+		clock:   clock,
+		sinks:   sinks,
+		wake:    make(chan struct{}, 1),
+		state:   StateHealthy,
+
+		nextScheduled: policy.RebootSchedule.Next(clock.Now()),
+	}
+}
+
+// AddSink registers another sink. It must be called before Run.
+func (m *Monitor) AddSink(sink Sink) {
+	m.sinks = append(m.sinks, sink)
+}
+
+// SetName names the router for logs, events and samples, so several
+// monitors can share sinks. It must be called before Run.
+func (m *Monitor) SetName(name string) {
+	m.name = name
+}
+
+// SetUsageStore loads the router's usage from store and keeps it there.
+// It must be called after SetName and before Run.
+func (m *Monitor) SetUsageStore(store *UsageStore) error {
+	usage, err := store.Load(m.name)
+	if err != nil {
+		return err
+	}
+	m.usage = usage
+	m.usageStore = store
+	return nil
+}
+
+// Name returns the router name, empty unless SetName was called.
+func (m *Monitor) Name() string {
+	return m.name
+}
+
+// logger returns the global logger tagged with the router name. It is built
+// on each call so it follows the output configured by the TUI or headless
+// mode.
+func (m *Monitor) logger() *log.Logger {
+	return routerLogger(m.name)
+}
+
+func routerLogger(name string) *log.Logger {
+	if name == "" {
+		return log.Default()
+	}
+	return log.With("router", name)
+}
+
+// State returns the current state.
+func (m *Monitor) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// MonitorStatus is a point-in-time view of the monitor for other goroutines.
+type MonitorStatus struct {
+	State      State     `json:"state"`
+	Paused     bool      `json:"paused"`
+	Sample     Sample    `json:"sample"`
+	LastReboot time.Time `json:"last_reboot"`
+	NextReboot time.Time `json:"next_reboot"` // next planned reboot, zero without a schedule
+	QuietUntil time.Time `json:"quiet_until"` // end of the current quiet window, zero outside one
+	Radio      Radio     `json:"radio"`
+}
+
+// Status returns a snapshot of the monitor.
+func (m *Monitor) Status() MonitorStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MonitorStatus{
+		State:      m.state,
+		Paused:     m.paused,
+		Sample:     m.sample,
+		LastReboot: m.lastReboot,
+		NextReboot: m.nextScheduled,
+		QuietUntil: m.policy.QuietWindows.QuietUntil(m.clock.Now()),
+		Radio:      m.radio,
+	}
+}
+
+// Pause suspends (or resumes) automatic reboots. Manual reboots still go
+// through.
+func (m *Monitor) Pause(paused bool) {
+	m.mu.Lock()
+	m.paused = paused
+	m.mu.Unlock()
+	m.logger().Info("auto-reboot", "paused", paused)
+}
+
+// Paused reports whether automatic reboots are suspended.
+func (m *Monitor) Paused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.paused
+}
+
+// RequestReboot asks the monitor to reboot the router on its next step,
+// waking it if it is sleeping.
+func (m *Monitor) RequestReboot() {
+	m.mu.Lock()
+	m.rebootRequested = true
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Monitor) takeRebootRequest() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	requested := m.rebootRequested
+	m.rebootRequested = false
+	return requested
+}
+
+// Run polls the router until ctx is cancelled, then logs out so no admin
+// session is left behind.
+func (m *Monitor) Run(ctx context.Context) error {
+	defer m.saveUsage()
+	defer m.logout()
+	for {
+		delay := m.Step(ctx)
+
+		sleepCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-m.wake:
+				cancel()
+			case <-sleepCtx.Done():
+			}
+		}()
+		m.clock.Sleep(sleepCtx, delay)
+		cancel()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *Monitor) logout() {
+	if m.client.SessionID() == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.session.Close(ctx); err != nil {
+		m.logger().Warn("logout failed", "error", err)
+	}
+}
+
+// Step performs one poll of the router, advances the state machine and
+// returns how long to wait before the next step.
+func (m *Monitor) Step(ctx context.Context) time.Duration {
+	logger := m.logger()
+	// A fresh logger each step picks up level changes made from the TUI.
+	m.client.Logger = logger
+	// A manual reboot runs at once, even while cooling down.
+	if m.takeRebootRequest() {
+		logger.Warn("manual reboot requested")
+		return m.reboot(ctx, "manual")
+	}
+	if m.state == StateCoolingDown {
+		if wait := m.cooldownUntil.Sub(m.clock.Now()); wait > 0 {
+			return wait
+		}
+	}
+	if m.scheduledRebootDue() {
+		logger.Warn("scheduled reboot", "schedule", m.policy.RebootSchedule)
+		return m.reboot(ctx, "scheduled")
+	}
+	m.applyRadioChanges(ctx)
+
+	status, err := m.client.Status(ctx)
+	if err != nil {
+		logger.Error("monitoring cycle failed", "error", err, "sleep", m.policy.BaseDelay)
+		return m.policy.BaseDelay
+	}
+
+	sample, err := readSample(status, logger)
+	if err != nil {
+		logger.Warn(err.Error(), "sleep", m.policy.BaseDelay)
+		return m.policy.BaseDelay
+	}
+	sample.Router = m.name
+	m.account(sample)
+	sample.Usage = m.usage
+	sample.Rates = m.rates.add(sample, m.clock.Now())
+	m.mu.Lock()
+	m.sample = sample
+	m.mu.Unlock()
+	m.sinks.Sample(sample)
+	m.guardBudget(ctx)
+	m.releaseBandLock(ctx)
+	m.readRadio(ctx)
+
+	total := sample.TxBytes + sample.RxBytes
+	logger.Debug("Total traffic", "MB", float32(total)*0.000001)
+
+	if sample.Freq == "NA" {
+		logger.Debug("No Data Connection", "sleep", m.policy.BaseDelay)
+		return m.policy.BaseDelay
+	}
+	logger.Debug("4G available", "FREQ", sample.Freq)
+
+	if sample.Freq5G != "NA" {
+		logger.Debug("5G available", "FREQ_5G", sample.Freq5G)
+		if m.outage {
+			m.event(Event5GRecovered, "5G came back")
+		}
+		m.outage = false
+		m.unrecovered = 0
+		m.alerted = false
+		m.holdNote = ""
+		m.resetLadder()
+		m.noteGoodBand(sample)
+		m.transition(StateHealthy, "5G available")
+		m.setBaseline(sample)
+		return m.policy.BaseDelay
+	}
+
+	if !m.baseline {
+		m.setBaseline(sample)
+	}
+
+	// A reboot does not end an outage, so one that 5G did not come back
+	// from carries on rather than starting another.
+	if !m.outage {
+		m.outage = true
+		m.transition(State5GLost, "FREQ_5G missing")
+		m.event(Event5GLost, "FREQ_5G missing")
+		m.transition(StateRecovering, "waiting for 5G")
+		m.lockGoodBand(ctx)
+	} else if m.state == StateCoolingDown {
+		m.transition(StateRecovering, "5G still missing after reboot")
+	}
+
+	downtime := sample.Uptime - m.lastSeen5G
+	bytesUsed := total - m.bytesAt5G
+	if downtime < int(m.policy.RecoverTime.Seconds()) && bytesUsed < m.policy.RecoverBytes {
+		logger.Warn("5G recovery", "downtime(sec)", downtime)
+		logger.Warn("4G data used", "MB", float32(bytesUsed)*0.000001)
+		return 0
+	}
+
+	if m.Paused() {
+		m.noteHold("paused", "5G not recovered, auto-reboot paused", "downtime(sec)", downtime)
+		return m.policy.BaseDelay
+	}
+	if until := m.policy.QuietWindows.QuietUntil(m.clock.Now()); !until.IsZero() {
+		m.noteHold("quiet "+until.String(), "5G not recovered, quiet window", "downtime(sec)", downtime, "until", until.Format("15:04"))
+		return m.policy.BaseDelay
+	}
+
+	if m.escalate(ctx) {
+		return m.policy.BaseDelay
+	}
+	if hold, reason := m.rebootHold(); reason != "" {
+		m.holdReboot(reason)
+		m.noteHold(reason+" "+hold.String(), "5G not recovered, reboot held back", "reason", reason, "until", hold.Format("15:04:05"))
+		return m.policy.BaseDelay
+	}
+	m.holdNote = ""
+
+	logger.Warn("FREQ_5G not present, initiating reboot")
+	delay := m.reboot(ctx, "5G not recovered")
+	if m.state == StateCoolingDown {
+		m.unrecovered++
+	}
+	return delay
+}
+
+// rebootHold returns until when a recovery reboot must wait and why, or an
+// empty reason if it may go ahead. Reboots that did not bring 5G back are
+// spaced out exponentially, and the reboot caps count every reboot.
+func (m *Monitor) rebootHold() (time.Time, string) {
+	now := m.clock.Now()
+	m.rebootTimes = pruneBefore(m.rebootTimes, now.Add(-24*time.Hour))
+
+	if max := m.policy.MaxRebootsDay; max > 0 && len(m.rebootTimes) >= max {
+		return m.rebootTimes[len(m.rebootTimes)-max].Add(24 * time.Hour), "daily reboot cap"
+	}
+	if max := m.policy.MaxRebootsHour; max > 0 {
+		hourly := pruneBefore(m.rebootTimes, now.Add(-time.Hour))
+		if len(hourly) >= max {
+			return hourly[len(hourly)-max].Add(time.Hour), "hourly reboot cap"
+		}
+	}
+	if m.unrecovered > 0 && len(m.rebootTimes) > 0 {
+		until := m.rebootTimes[len(m.rebootTimes)-1].Add(m.policy.rebootBackoff(m.unrecovered))
+		if now.Before(until) {
+			return until, "backoff"
+		}
+	}
+	return time.Time{}, ""
+}
+
+// noteHold logs why a recovery reboot is held back when the reason, named
+// by note, starts or changes. Holds last for hours, and the steps in
+// between would otherwise repeat the line every poll.
+func (m *Monitor) noteHold(note, msg string, keyvals ...interface{}) {
+	if note == m.holdNote {
+		return
+	}
+	m.holdNote = note
+	m.logger().Warn(msg, keyvals...)
+}
+
+// holdReboot raises the 5G unavailable alert the first time a reboot cap
+// stops recovery during an outage.
+func (m *Monitor) holdReboot(reason string) {
+	if reason == "backoff" || m.alerted {
+		return
+	}
+	m.alerted = true
+	m.logger().Error("5G unavailable in area, reboots capped", "reason", reason, "reboots(24h)", len(m.rebootTimes))
+	m.transition(StateUnavailable, reason)
+	m.event(Event5GUnavailable, reason)
+}
+
+// scheduledRebootDue reports whether a planned reboot has come due. One
+// that falls into a quiet window or a pause is skipped, not postponed.
+func (m *Monitor) scheduledRebootDue() bool {
+	now := m.clock.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nextScheduled.IsZero() || now.Before(m.nextScheduled) {
+		return false
+	}
+	m.nextScheduled = m.policy.RebootSchedule.Next(now)
+	switch {
+	case m.paused:
+		m.logger().Warn("scheduled reboot skipped, auto-reboot paused")
+		return false
+	case m.policy.QuietWindows.Contains(now):
+		m.logger().Warn("scheduled reboot skipped, quiet window")
+		return false
+	}
+	return true
+}
+
+// reboot logs in and restarts the router, returning the delay before the
+// next step.
+func (m *Monitor) reboot(ctx context.Context, cause string) time.Duration {
+	logger := m.logger()
+	m.transition(StateRebooting, cause)
+
+	if err := m.session.Login(ctx); err != nil {
+		logger.Warn("login failed", "error", err, "sleep", loginRetryDelay)
+		m.sinks.Event(m.newEvent(EventLoginFailed, cause, err))
+		m.transition(StateRecovering, "login failed")
+		return loginRetryDelay
+	}
+
+	if err := m.session.Reboot(ctx); err != nil {
+		logger.Error("reboot sequence failed", "error", err, "sleep", rebootRetryDelay)
+		m.transition(StateRecovering, "reboot failed")
+		return rebootRetryDelay
+	}
+
+	m.mu.Lock()
+	m.lastReboot = m.clock.Now()
+	m.mu.Unlock()
+	m.rebootTimes = append(m.rebootTimes, m.lastReboot)
+	m.event(EventReboot, cause)
+	logger.Info("reboot sequence completed", "sleep", m.policy.RebootSleep)
+	m.cooldownUntil = m.clock.Now().Add(m.policy.RebootSleep)
+	// Counters restart with the router, so measure the next outage afresh.
+	m.baseline = false
+	m.resetLadder()
+	m.transition(StateCoolingDown, "rebooted")
+	return m.policy.RebootSleep
+}
+
+func (m *Monitor) setBaseline(sample Sample) {
+	m.lastSeen5G = sample.Uptime
+	m.bytesAt5G = sample.TxBytes + sample.RxBytes
+	m.baseline = true
+}
+
+func (m *Monitor) transition(to State, cause string) {
+	if m.state == to {
+		return
+	}
+	ev := m.newEvent(EventStateChanged, cause, nil)
+	ev.From, ev.To = m.state, to
+	m.mu.Lock()
+	m.state = to
+	m.mu.Unlock()
+	m.sinks.Event(ev)
+}
+
+func (m *Monitor) event(kind EventKind, cause string) {
+	m.sinks.Event(m.newEvent(kind, cause, nil))
+}
+
+func (m *Monitor) newEvent(kind EventKind, cause string, err error) Event {
+	return Event{
+		Router:  m.name,
+		Time:    m.clock.Now(),
+		Kind:    kind,
+		From:    m.state,
+		To:      m.state,
+		Cause:   cause,
+		Sample:  m.sample,
+		Bytes4G: m.sample.TxBytes + m.sample.RxBytes - m.bytesAt5G,
+		Err:     err,
+	}
+}
+
+// readSample extracts the telemetry the monitor cares about from a status
+// reply.
+func readSample(status *vn007.Status, logger *log.Logger) (Sample, error) {
+	if err := status.Require("uptime", "wan_rx_bytes", "wan_tx_bytes"); err != nil {
+		return Sample{}, err
+	}
+
+	sample := Sample{
+		Uptime:  status.Uptime,
+		RxBytes: status.RxBytes,
+		TxBytes: status.TxBytes,
+		RSRQ:    status.RSRQ,
+		RSRQ5G:  status.RSRQ5G,
+		Freq:    "NA",
+		Freq5G:  "NA",
+		Detail:  readDetail(status),
+	}
+	if !status.Has("RSRQ") {
+		logger.Warn("RSRQ not found")
+	}
+	if !status.Has("RSRQ_5G") {
+		logger.Warn("RSRQ 5G not found")
+	}
+	if status.Has("FREQ") {
+		sample.Freq = strconv.Itoa(status.Freq)
+	}
+	if status.Has("FREQ_5G") {
+		sample.Freq5G = strconv.Itoa(status.Freq5G)
+	}
+	return sample, nil
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"rpfilomeno.xyz/vn007go/vn007"
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+// recoverSecs is the default recovery window in router uptime seconds.
+var recoverSecs = int(DefaultPolicy.RecoverTime.Seconds())
+
+// fakeClock only moves when the monitor sleeps.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(_ context.Context, d time.Duration) error {
+	c.now = c.now.Add(d)
+	return nil
+}
+
+type recordingSink struct {
+	samples []Sample
+	events  []Event
+}
+
+func (s *recordingSink) Sample(sample Sample) { s.samples = append(s.samples, sample) }
+func (s *recordingSink) Event(ev Event)       { s.events = append(s.events, ev) }
+
+func (s *recordingSink) count(kind EventKind) int {
+	n := 0
+	for _, ev := range s.events {
+		if ev.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestMonitor(t *testing.T) (*Monitor, *vn007test.Router, *fakeClock, *recordingSink) {
+	t.Helper()
+	router := vn007test.NewRouter()
+	t.Cleanup(router.Close)
+
+	clock := &fakeClock{now: time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)}
+	sink := &recordingSink{}
+	m := NewMonitor(router.Client(), DefaultPolicy, clock, sink)
+	m.client.Retry = vn007.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	return m, router, clock, sink
+}
+
+// step runs one monitor step and lets the fake clock absorb its delay.
+func step(t *testing.T, m *Monitor, clock *fakeClock) time.Duration {
+	t.Helper()
+	delay := m.Step(context.Background())
+	clock.Sleep(context.Background(), delay)
+	return delay
+}
+
+func TestMonitor_HealthyStaysHealthy(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+
+	for i := 0; i < 3; i++ {
+		if delay := step(t, m, clock); delay != DefaultPolicy.BaseDelay {
+			t.Errorf("step %d delay = %s, want %s", i, delay, DefaultPolicy.BaseDelay)
+		}
+		router.Advance(1, 1000, 1000)
+	}
+
+	if m.State() != StateHealthy {
+		t.Errorf("state = %s, want Healthy", m.State())
+	}
+	if len(sink.samples) != 3 {
+		t.Errorf("samples = %d, want 3", len(sink.samples))
+	}
+	if router.Reboots() != 0 {
+		t.Errorf("reboots = %d, want 0", router.Reboots())
+	}
+}
+
+func TestMonitor_5GRecoversWithinWindow(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(1, 0, 0)
+	step(t, m, clock)
+	if m.State() != StateRecovering {
+		t.Fatalf("state = %s, want Recovering", m.State())
+	}
+	if sink.count(Event5GLost) != 1 {
+		t.Errorf("5G lost events = %d, want 1", sink.count(Event5GLost))
+	}
+
+	router.Restore5G()
+	router.Advance(1, 0, 0)
+	step(t, m, clock)
+	if m.State() != StateHealthy {
+		t.Errorf("state = %s, want Healthy", m.State())
+	}
+	if sink.count(Event5GRecovered) != 1 {
+		t.Errorf("5G recovered events = %d, want 1", sink.count(Event5GRecovered))
+	}
+	if router.Reboots() != 0 {
+		t.Errorf("reboots = %d, want 0", router.Reboots())
+	}
+}
+
+func TestMonitor_RebootsWhen5GStaysDown(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(1, 0, 0)
+	step(t, m, clock)
+
+	router.Advance(recoverSecs, 0, 0)
+	if delay := step(t, m, clock); delay != DefaultPolicy.RebootSleep {
+		t.Errorf("delay after reboot = %s, want %s", delay, DefaultPolicy.RebootSleep)
+	}
+	if m.State() != StateCoolingDown {
+		t.Fatalf("state = %s, want CoolingDown", m.State())
+	}
+	if router.Reboots() != 1 {
+		t.Errorf("reboots = %d, want 1", router.Reboots())
+	}
+	if sink.count(EventReboot) != 1 {
+		t.Errorf("reboot events = %d, want 1", sink.count(EventReboot))
+	}
+
+	// The fake router brings 5G back on reboot.
+	step(t, m, clock)
+	if m.State() != StateHealthy {
+		t.Errorf("state = %s after cooldown, want Healthy", m.State())
+	}
+}
+
+func TestMonitor_OutageSpansReboots(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.RebootBackoff = 0
+	router.RebootRestores5G(false)
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock) // reboot 1
+	step(t, m, clock) // back up without 5G
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock) // reboot 2
+	if router.Reboots() != 2 || sink.count(Event5GLost) != 1 {
+		t.Fatalf("reboots = %d, 5G losses = %d; want one outage over two reboots", router.Reboots(), sink.count(Event5GLost))
+	}
+
+	router.Restore5G()
+	step(t, m, clock)
+	if m.State() != StateHealthy || sink.count(Event5GRecovered) != 1 {
+		t.Errorf("state = %s, recoveries = %d after cooldown with 5G; want the outage closed", m.State(), sink.count(Event5GRecovered))
+	}
+}
+
+func TestMonitor_RebootsWhen4GBudgetUsed(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+
+	step(t, m, clock)
+	router.Drop5G()
+	step(t, m, clock)
+	router.Advance(1, DefaultPolicy.RecoverBytes, 0)
+	step(t, m, clock)
+
+	if router.Reboots() != 1 {
+		t.Errorf("reboots = %d, want 1", router.Reboots())
+	}
+}
+
+func TestMonitor_CooldownWaitsBeforePolling(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+
+	router.Drop5G()
+	router.RebootRestores5G(false)
+	step(t, m, clock)
+	router.Advance(recoverSecs, 0, 0)
+	m.Step(context.Background())
+
+	polls := router.Calls(vn007.CmdStatus)
+	clock.Sleep(context.Background(), DefaultPolicy.RebootSleep/2)
+	if delay := m.Step(context.Background()); delay != DefaultPolicy.RebootSleep/2 {
+		t.Errorf("delay during cooldown = %s, want %s", delay, DefaultPolicy.RebootSleep/2)
+	}
+	if router.Calls(vn007.CmdStatus) != polls {
+		t.Errorf("polled the router during cooldown")
+	}
+
+	clock.Sleep(context.Background(), DefaultPolicy.RebootSleep/2)
+	step(t, m, clock)
+	if m.State() != StateRecovering {
+		t.Errorf("state = %s after cooldown without 5G, want Recovering", m.State())
+	}
+	if router.Reboots() != 1 {
+		t.Errorf("reboots = %d, want 1 (fresh recovery window after reboot)", router.Reboots())
+	}
+}
+
+func TestMonitor_ManualRebootDuringCooldown(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+
+	m.RequestReboot()
+	step(t, m, clock)
+	if m.State() != StateCoolingDown {
+		t.Fatalf("state = %s, want CoolingDown", m.State())
+	}
+	m.RequestReboot()
+	m.Step(context.Background())
+	if router.Reboots() != 2 {
+		t.Errorf("reboots = %d, want the second request served during cooldown", router.Reboots())
+	}
+}
+
+func TestMonitor_LoginFailure(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	router.RejectLogins(true)
+
+	step(t, m, clock)
+	router.Drop5G()
+	step(t, m, clock)
+	router.Advance(recoverSecs+1, 0, 0)
+	if delay := step(t, m, clock); delay != loginRetryDelay {
+		t.Errorf("delay after failed login = %s, want %s", delay, loginRetryDelay)
+	}
+
+	if m.State() != StateRecovering {
+		t.Errorf("state = %s, want Recovering", m.State())
+	}
+	if sink.count(EventLoginFailed) != 1 {
+		t.Errorf("login failed events = %d, want 1", sink.count(EventLoginFailed))
+	}
+	if router.Reboots() != 0 {
+		t.Errorf("reboots = %d, want 0", router.Reboots())
+	}
+}
+
+func TestMonitor_NamedRoutersTagOutput(t *testing.T) {
+	home, homeRouter, clock, sink := newTestMonitor(t)
+	home.SetName("home")
+	officeRouter := vn007test.NewRouter()
+	t.Cleanup(officeRouter.Close)
+	office := NewMonitor(officeRouter.Client(), DefaultPolicy, clock, sink)
+	office.SetName("office")
+
+	step(t, home, clock)
+	step(t, office, clock)
+	homeRouter.Drop5G()
+	step(t, home, clock)
+
+	if len(sink.samples) != 3 || sink.samples[0].Router != "home" || sink.samples[1].Router != "office" {
+		t.Errorf("samples = %+v, want tagged by router", sink.samples)
+	}
+	for _, ev := range sink.events {
+		if ev.Router != "home" {
+			t.Errorf("event %s from %q, want home", ev.Kind, ev.Router)
+		}
+	}
+	if office.State() != StateHealthy || home.State() != StateRecovering {
+		t.Errorf("states = %s, %s; routers must not share state", home.State(), office.State())
+	}
+}
+
+func TestMonitor_SessionExpiresOnClock(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+	m.policy.Experimental = true
+	m.RequestRadio(RadioChange{})
+	step(t, m, clock)
+	clock.now = clock.now.Add(vn007.DefaultSessionTTL)
+	m.RequestRadio(RadioChange{})
+	m.Step(context.Background())
+	if n := router.Calls(vn007.CmdLogin); n != 2 {
+		t.Errorf("logins = %d, want a new login once idle for the session TTL", n)
+	}
+}
+
+func TestMonitor_RunLogsOutOnStop(t *testing.T) {
+	m, router, _, _ := newTestMonitor(t)
+	if err := m.session.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.clock = realClock{}
+	m.Run(ctx)
+
+	if router.Sessions() != 0 {
+		t.Errorf("open sessions after Run = %d, want 0", router.Sessions())
+	}
+	if router.Calls(vn007.CmdLogout) != 1 {
+		t.Errorf("logouts = %d, want 1", router.Calls(vn007.CmdLogout))
+	}
+}
+
+func TestMonitor_NumericAndMissingFields(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+
+	router.Set("uptime", 900)
+	router.Set("RSRQ", -12)
+	router.Set("FREQ_5G", 627264)
+	step(t, m, clock)
+	if len(sink.samples) != 1 || sink.samples[0].Uptime != 900 || sink.samples[0].RSRQ != -12 || sink.samples[0].Freq5G != "627264" {
+		t.Fatalf("samples = %+v, want numeric fields decoded", sink.samples)
+	}
+
+	router.Set("wan_rx_bytes", nil)
+	if delay := step(t, m, clock); delay != DefaultPolicy.BaseDelay {
+		t.Errorf("delay = %s, want poll interval after an incomplete reply", delay)
+	}
+	if len(sink.samples) != 1 {
+		t.Errorf("incomplete reply produced a sample")
+	}
+}
+
+func TestMonitor_SampleCarriesDetail(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	router.Set("temperature", nil)
+	step(t, m, clock)
+
+	if len(sink.samples) != 1 {
+		t.Fatalf("samples = %d, want 1", len(sink.samples))
+	}
+	d := sink.samples[0].Detail
+	if d.RSRP == nil || *d.RSRP != -95 || d.Band5G != "n78" || d.Operator != "CHN-UNICOM" || d.WanIPv4 != "10.64.12.34" {
+		t.Errorf("detail = %+v, want the fake router's cell", d)
+	}
+	if d.Temperature != nil {
+		t.Errorf("temperature = %d, want nil when not reported", *d.Temperature)
+	}
+}
+
+func TestMonitor_ScheduledReboot(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.RebootSchedule.Set("30 12 * * *")
+	m.nextScheduled = m.policy.RebootSchedule.Next(clock.now)
+
+	step(t, m, clock)
+	if router.Reboots() != 0 {
+		t.Fatalf("rebooted before the schedule")
+	}
+	clock.now = clock.now.Add(30 * time.Minute)
+	step(t, m, clock)
+	if router.Reboots() != 1 || sink.count(EventReboot) != 1 {
+		t.Fatalf("reboots = %d, want 1 at 12:30", router.Reboots())
+	}
+	for _, ev := range sink.events {
+		if ev.Kind == EventReboot && ev.Cause != "scheduled" {
+			t.Errorf("reboot cause = %q, want scheduled", ev.Cause)
+		}
+	}
+	if want := time.Date(2024, 10, 2, 12, 30, 0, 0, time.UTC); !m.Status().NextReboot.Equal(want) {
+		t.Errorf("next reboot = %s, want %s", m.Status().NextReboot, want)
+	}
+}
+
+func TestMonitor_QuietWindowSuppressesReboots(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.QuietWindows.Set("11:00-13:00")
+	m.policy.RebootSchedule.Set("30 12 * * *")
+	m.nextScheduled = m.policy.RebootSchedule.Next(clock.now)
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(recoverSecs+1, 0, 0)
+	step(t, m, clock)
+	clock.now = clock.now.Add(30 * time.Minute)
+	step(t, m, clock)
+	if router.Reboots() != 0 {
+		t.Fatalf("rebooted %d times in a quiet window", router.Reboots())
+	}
+	if !m.Status().QuietUntil.Equal(time.Date(2024, 10, 1, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("quiet until = %s, want 13:00", m.Status().QuietUntil)
+	}
+
+	m.RequestReboot()
+	step(t, m, clock)
+	if router.Reboots() != 1 || sink.count(EventReboot) != 1 {
+		t.Errorf("manual reboot in a quiet window: reboots = %d, want 1", router.Reboots())
+	}
+}
+
+func TestMonitor_LogsHoldOnce(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	m, router, clock, _ := newTestMonitor(t)
+	m.Pause(true)
+
+	step(t, m, clock)
+	router.Drop5G()
+	for i := 0; i < 5; i++ {
+		router.Advance(recoverSecs, 0, 0)
+		step(t, m, clock)
+	}
+	if n := strings.Count(buf.String(), "not recovered, auto-reboot paused"); n != 1 {
+		t.Errorf("paused hold logged %d times, want once", n)
+	}
+
+	m.Pause(false)
+	m.policy.MaxRebootsDay = 1
+	m.policy.RebootBackoff = 0
+	router.RebootRestores5G(false)
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock) // reboot
+	step(t, m, clock) // back up without 5G
+	for i := 0; i < 5; i++ {
+		router.Advance(recoverSecs, 0, 0)
+		step(t, m, clock)
+	}
+	if n := strings.Count(buf.String(), "reboot held back"); n != 1 {
+		t.Errorf("capped hold logged %d times, want once", n)
+	}
+}
+
+func TestMonitor_BacksOffAfterUnrecoveredReboot(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	router.Drop5G()
+	router.RebootRestores5G(false)
+
+	step(t, m, clock)
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock) // reboot 1
+	step(t, m, clock) // back up, still no 5G
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock)
+	if router.Reboots() != 1 {
+		t.Fatalf("reboots = %d, want 1 during backoff", router.Reboots())
+	}
+	if m.State() != StateRecovering || sink.count(Event5GUnavailable) != 0 {
+		t.Errorf("state = %s, alerts = %d; backoff is not an alert", m.State(), sink.count(Event5GUnavailable))
+	}
+
+	clock.now = clock.now.Add(DefaultPolicy.RebootBackoff)
+	step(t, m, clock) // reboot 2
+	step(t, m, clock)
+	router.Advance(recoverSecs, 0, 0)
+	clock.now = clock.now.Add(DefaultPolicy.RebootBackoff)
+	step(t, m, clock)
+	if router.Reboots() != 2 {
+		t.Errorf("reboots = %d, want 2; the second backoff doubles", router.Reboots())
+	}
+}
+
+func TestMonitor_RebootCapRaisesAlert(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.MaxRebootsHour = 2
+	m.policy.RebootBackoff, m.policy.MaxRebootBackoff = 0, 0
+	router.Drop5G()
+	router.RebootRestores5G(false)
+
+	for i := 0; i < 12; i++ {
+		router.Advance(recoverSecs, 0, 0)
+		step(t, m, clock)
+	}
+	if router.Reboots() != 2 {
+		t.Errorf("reboots = %d, want the hourly cap of 2", router.Reboots())
+	}
+	if m.State() != StateUnavailable || sink.count(Event5GUnavailable) != 1 {
+		t.Errorf("state = %s, alerts = %d; want Unavailable and one alert", m.State(), sink.count(Event5GUnavailable))
+	}
+
+	router.Restore5G()
+	step(t, m, clock)
+	if m.State() != StateHealthy || sink.count(Event5GRecovered) != 1 {
+		t.Errorf("state = %s after 5G came back, want Healthy", m.State())
+	}
+
+	// The cap frees up after an hour and the next outage alerts again.
+	clock.now = clock.now.Add(time.Hour)
+	router.Drop5G()
+	for i := 0; i < 12; i++ {
+		router.Advance(recoverSecs, 0, 0)
+		step(t, m, clock)
+	}
+	if router.Reboots() != 4 || sink.count(Event5GUnavailable) != 2 {
+		t.Errorf("reboots = %d, alerts = %d; want 4 and 2", router.Reboots(), sink.count(Event5GUnavailable))
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+// actionBandLock is the recovery action of locking to the last good NR
+// band. It is not a ladder step: it runs as soon as 5G drops.
+const actionBandLock = "bandlock"
+
+// Radio is the network mode and band locks of a router, as last read.
+type Radio struct {
+	Mode  vn007.NetworkMode `json:"mode,omitempty"` // empty until read
+	Bands vn007.BandLock    `json:"bands"`
+}
+
+// RadioChange is a network mode or band lock change asked for by the user.
+// Empty fields are left as they are; an empty RadioChange just reads the
+// current settings.
+type RadioChange struct {
+	Mode  vn007.NetworkMode
+	Bands *vn007.BandLock
+}
+
+// RequestRadio asks the monitor to apply change on its next step, waking
+// it if it is sleeping. Without Policy.Experimental the request is refused,
+// as the network mode and band lock commands are unconfirmed.
+func (m *Monitor) RequestRadio(change RadioChange) {
+	if !m.policy.Experimental {
+		m.logger().Warn("network mode and band locks need --experimental")
+		return
+	}
+	m.mu.Lock()
+	m.radioChanges = append(m.radioChanges, change)
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Monitor) takeRadioChanges() []RadioChange {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changes := m.radioChanges
+	m.radioChanges = nil
+	return changes
+}
+
+// applyRadioChanges sends the requested changes to the router. A band lock
+// set by hand ends any temporary lock, which is then not undone.
+func (m *Monitor) applyRadioChanges(ctx context.Context) {
+	logger := m.logger()
+	for _, change := range m.takeRadioChanges() {
+		m.radioStale = true
+		if change.Mode == "" && change.Bands == nil {
+			continue
+		}
+		err := m.session.Do(ctx, func(ctx context.Context) error {
+			if change.Mode != "" {
+				if err := m.client.SetNetworkMode(ctx, change.Mode); err != nil {
+					return err
+				}
+			}
+			if change.Bands != nil {
+				return m.client.SetBandLock(ctx, *change.Bands)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error("changing radio settings failed", "error", err)
+			continue
+		}
+		if change.Mode != "" {
+			logger.Info("network mode set", "mode", change.Mode)
+		}
+		if change.Bands != nil {
+			logger.Info("band lock set", "bands", change.Bands)
+			m.bandRestore = nil
+		}
+	}
+}
+
+// readRadio reads the network mode and band locks after a change or when
+// asked to. It needs a login, so it does not run unprompted.
+func (m *Monitor) readRadio(ctx context.Context) {
+	if !m.radioStale {
+		return
+	}
+	m.radioStale = false
+	var radio Radio
+	err := m.session.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if radio.Mode, err = m.client.NetworkMode(ctx); err != nil {
+			return err
+		}
+		radio.Bands, err = m.client.BandLock(ctx)
+		return err
+	})
+	if err != nil {
+		m.logger().Warn("error reading radio settings", "error", err)
+		return
+	}
+	m.mu.Lock()
+	m.radio = radio
+	m.mu.Unlock()
+}
+
+// Radio returns the network mode and band locks as last read.
+func (m *Monitor) Radio() Radio {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.radio
+}
+
+// noteGoodBand remembers the NR band of a sample with 5G.
+func (m *Monitor) noteGoodBand(sample Sample) {
+	if bands, err := vn007.ParseBands(sample.Detail.Band5G); err == nil && len(bands) == 1 {
+		m.goodNRBand = bands[0]
+	}
+}
+
+// lockGoodBand locks NR to the last band 5G was seen on for BandLockHold,
+// so the modem does not wander off to a band that will not carry it. The
+// lock in force before is put back afterwards.
+func (m *Monitor) lockGoodBand(ctx context.Context) {
+	if m.policy.BandLockHold <= 0 || m.goodNRBand == 0 || m.bandRestore != nil {
+		return
+	}
+	var previous vn007.BandLock
+	err := m.session.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if previous, err = m.client.BandLock(ctx); err != nil {
+			return err
+		}
+		return m.client.SetBandLock(ctx, vn007.BandLock{LTE: previous.LTE, NR: []int{m.goodNRBand}})
+	})
+	m.sinks.Event(m.newEvent(EventRecoveryAction, actionBandLock, err))
+	if err != nil {
+		m.logger().Error("locking to the last good NR band failed", "band", m.goodNRBand, "error", err)
+		return
+	}
+	m.logger().Warn("locked to the last good NR band", "band", m.goodNRBand, "for", m.policy.BandLockHold)
+	m.bandRestore = &previous
+	m.bandLockUntil = m.clock.Now().Add(m.policy.BandLockHold)
+	m.radioStale = true
+}
+
+// releaseBandLock puts back the band lock that lockGoodBand replaced once
+// its time is up. A failed attempt is retried on the next step.
+func (m *Monitor) releaseBandLock(ctx context.Context) {
+	if m.bandRestore == nil || m.clock.Now().Before(m.bandLockUntil) {
+		return
+	}
+	restore := *m.bandRestore
+	err := m.session.Do(ctx, func(ctx context.Context) error {
+		return m.client.SetBandLock(ctx, restore)
+	})
+	if err != nil {
+		m.logger().Error("lifting the NR band lock failed", "error", err)
+		return
+	}
+	m.logger().Info("NR band lock lifted", "bands", restore)
+	m.bandRestore = nil
+	m.radioStale = true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+func TestMonitor_AppliesRadioRequest(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+	m.policy.Experimental = true
+
+	step(t, m, clock)
+	if router.Sessions() != 0 || m.Radio().Mode != "" {
+		t.Fatal("read radio settings unprompted")
+	}
+
+	lock := vn007.BandLock{LTE: []int{3}, NR: []int{78}}
+	m.RequestRadio(RadioChange{Mode: vn007.NetworkMode5GSA, Bands: &lock})
+	step(t, m, clock)
+	if router.NetworkMode() != vn007.NetworkMode5GSA || !reflect.DeepEqual(router.BandLock(), lock) {
+		t.Fatalf("router mode = %q, bands = %v", router.NetworkMode(), router.BandLock())
+	}
+	if radio := m.Radio(); radio.Mode != vn007.NetworkMode5GSA || !reflect.DeepEqual(radio.Bands, lock) {
+		t.Errorf("Radio = %+v, want the new settings read back", radio)
+	}
+}
+
+func TestMonitor_RadioRequestNeedsExperimental(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+
+	m.RequestRadio(RadioChange{Mode: vn007.NetworkMode4G})
+	step(t, m, clock)
+	if router.NetworkMode() != vn007.NetworkModeAuto || router.Calls(vn007.CmdNetworkMode) != 0 {
+		t.Errorf("router mode = %q; want the request refused", router.NetworkMode())
+	}
+}
+
+func TestMonitor_LocksLastGoodNRBand(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.BandLockHold = time.Minute
+	router.Set("BAND_5G", "n41")
+
+	step(t, m, clock)
+	router.Drop5G()
+	step(t, m, clock)
+	if lock := router.BandLock(); !reflect.DeepEqual(lock.NR, []int{41}) || sink.count(EventRecoveryAction) != 1 {
+		t.Fatalf("band lock = %v, want n41", lock)
+	}
+
+	router.Restore5G()
+	clock.now = clock.now.Add(30 * time.Second)
+	step(t, m, clock)
+	if router.BandLock().IsZero() {
+		t.Fatal("lock lifted early")
+	}
+	clock.now = clock.now.Add(30 * time.Second)
+	step(t, m, clock)
+	if lock := router.BandLock(); !lock.IsZero() {
+		t.Errorf("band lock = %v after the hold, want the old lock back", lock)
+	}
+}
+
+func TestMonitor_NoBandLockByDefault(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+
+	step(t, m, clock)
+	router.Drop5G()
+	step(t, m, clock)
+	if router.Calls(vn007.CmdBandLock) != 0 {
+		t.Errorf("band lock calls = %d, want none", router.Calls(vn007.CmdBandLock))
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Recovery actions, mildest first.
+const (
+	actionReattach = "reattach" // detach the modem from the network and attach again
+	actionData     = "data"     // switch mobile data off and on
+	actionReboot   = "reboot"   // full reboot, the last resort
+)
+
+// defaultStepTimeout is how long a recovery step without an explicit
+// timeout gets to bring 5G back.
+const defaultStepTimeout = 30 * time.Second
+
+// RecoveryStep is one rung of the escalation ladder: an action and how long
+// to wait for 5G after it before trying the next.
+type RecoveryStep struct {
+	Action  string
+	Timeout time.Duration
+}
+
+// Ladder is the escalation the monitor climbs once 5G has stayed down past
+// the recovery window, written as "reattach:20s,data:30s,reboot". It always
+// ends with a reboot.
+type Ladder []RecoveryStep
+
+func (l Ladder) String() string {
+	parts := make([]string, len(l))
+	for i, s := range l {
+		parts[i] = s.Action
+		if s.Action != actionReboot {
+			parts[i] += ":" + s.Timeout.String()
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *Ladder) Set(spec string) error {
+	var ladder Ladder
+	for _, part := range strings.Split(spec, ",") {
+		action, timeout, hasTimeout := strings.Cut(strings.TrimSpace(part), ":")
+		step := RecoveryStep{Action: action, Timeout: defaultStepTimeout}
+		switch action {
+		case actionReattach, actionData:
+		case actionReboot:
+			if hasTimeout {
+				return fmt.Errorf("reboot takes no timeout, it waits reboot-sleep")
+			}
+			step.Timeout = 0
+		default:
+			return fmt.Errorf("unknown recovery action %q, want reattach, data or reboot", action)
+		}
+		if hasTimeout {
+			d, err := time.ParseDuration(timeout)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid timeout %q for %s", timeout, action)
+			}
+			step.Timeout = d
+		}
+		ladder = append(ladder, step)
+	}
+	for i, step := range ladder {
+		if (step.Action == actionReboot) != (i == len(ladder)-1) {
+			return fmt.Errorf("the recovery steps must end with reboot, and only there")
+		}
+	}
+	*l = ladder
+	return nil
+}
+
+// escalate takes the next recovery step short of a reboot, and reports
+// whether the monitor should keep waiting for 5G rather than reboot. A step
+// that fails gives way to the next at once, as does a data toggle while the
+// budget keeps data off.
+func (m *Monitor) escalate(ctx context.Context) bool {
+	if m.clock.Now().Before(m.stepUntil) {
+		return true
+	}
+	logger := m.logger()
+	steps := m.policy.RecoverySteps
+	for m.nextStep < len(steps)-1 {
+		step := steps[m.nextStep]
+		m.nextStep++
+		if step.Action == actionData && m.usage.Guard == budgetData {
+			// Toggling would switch data back on past the budget.
+			logger.Debug("skipping data toggle, data is off for the 4G budget")
+			continue
+		}
+
+		logger.Warn("5G not recovered, trying "+step.Action, "timeout", step.Timeout)
+		err := m.recoveryAction(ctx, step.Action)
+		m.sinks.Event(m.newEvent(EventRecoveryAction, step.Action, err))
+		if err != nil {
+			logger.Error("recovery action failed", "action", step.Action, "error", err)
+			continue
+		}
+		m.stepUntil = m.clock.Now().Add(step.Timeout)
+		return true
+	}
+	return false
+}
+
+func (m *Monitor) recoveryAction(ctx context.Context, action string) error {
+	switch action {
+	case actionReattach:
+		return m.session.Do(ctx, m.client.Reattach)
+	case actionData:
+		return m.session.Do(ctx, m.client.ToggleMobileData)
+	}
+	return fmt.Errorf("unknown recovery action %q", action)
+}
+
+// resetLadder starts the next outage from the bottom of the ladder.
+func (m *Monitor) resetLadder() {
+	m.nextStep = 0
+	m.stepUntil = time.Time{}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+func TestLadder_Set(t *testing.T) {
+	var l Ladder
+	if err := l.Set("reattach:20s, data, reboot"); err != nil {
+		t.Fatal(err)
+	}
+	if l.String() != "reattach:20s,data:30s,reboot" {
+		t.Errorf("String = %q", l.String())
+	}
+	for _, spec := range []string{"", "reattach", "reboot,data", "reboot:1m", "data:soon,reboot", "reattach:-1s,reboot", "airplane,reboot"} {
+		if err := l.Set(spec); err == nil {
+			t.Errorf("Set(%q) accepted", spec)
+		}
+	}
+}
+
+func TestMonitor_ReattachBrings5GBack(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.RecoverySteps.Set("reattach:20s,data:30s,reboot")
+	router.ReattachRestores5G(true)
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock)
+	step(t, m, clock)
+	if router.Calls(vn007.CmdAirplaneMode) != 2 || sink.count(EventRecoveryAction) != 1 {
+		t.Fatalf("airplane calls = %d, want a reattach", router.Calls(vn007.CmdAirplaneMode))
+	}
+	step(t, m, clock)
+	if m.State() != StateHealthy || router.Reboots() != 0 || router.Calls(vn007.CmdMobileData) != 0 {
+		t.Errorf("state = %s, reboots = %d; want 5G back without escalating", m.State(), router.Reboots())
+	}
+}
+
+func TestMonitor_EscalatesToReboot(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.RecoverySteps.Set("reattach:20s,data:30s,reboot")
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock) // reattach right away, the recovery window is over
+
+	clock.now = clock.now.Add(17 * time.Second)
+	step(t, m, clock)
+	if router.Calls(vn007.CmdAirplaneMode) != 2 || router.Calls(vn007.CmdMobileData) != 0 {
+		t.Fatal("toggled data before the reattach timed out")
+	}
+	clock.now = clock.now.Add(time.Second)
+	step(t, m, clock) // data toggle
+	if router.Calls(vn007.CmdMobileData) != 2 || router.Reboots() != 0 {
+		t.Fatalf("data calls = %d, reboots = %d; want a data toggle", router.Calls(vn007.CmdMobileData), router.Reboots())
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+	step(t, m, clock)
+	if router.Reboots() != 1 || sink.count(EventRecoveryAction) != 2 {
+		t.Errorf("reboots = %d, recovery actions = %d; want 1 and 2", router.Reboots(), sink.count(EventRecoveryAction))
+	}
+}
+
+func TestMonitor_FailedStepEscalatesAtOnce(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.RecoverySteps.Set("reattach,reboot")
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(recoverSecs, 0, 0)
+	router.RejectLogins(true)
+	step(t, m, clock)
+	if sink.count(EventRecoveryAction) != 1 || sink.count(EventLoginFailed) != 1 {
+		t.Errorf("events = %+v, want the reboot tried right after the reattach failed", sink.events)
+	}
+	for _, ev := range sink.events {
+		if ev.Kind == EventRecoveryAction && ev.Err == nil {
+			t.Errorf("recovery event without the error")
+		}
+	}
+}
@@ -1,41 +1,36 @@
+//go:build live
+
 package main
 
 import (
-	"fmt"
-	"net/http"
+	"context"
 	"os"
 	"testing"
-	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/joho/godotenv"
+	"rpfilomeno.xyz/vn007go/vn007"
 )
 
-var sessionId string
+var client *vn007.Client
 
 func init() {
-	sessionId = ""
 	log.SetLevel(log.DebugLevel)
 }
 
-func TestSendRequestWithRetry_Monitoring(t *testing.T) {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
-	monitorPayload := MonitorPayload{
-		Cmd:       133,
-		Method:    "GET",
-		Language:  "EN",
-		SessionId: "",
+func liveClient() *vn007.Client {
+	if client == nil {
+		err := godotenv.Load()
+		if err != nil {
+			log.Fatal("Error loading .env file")
+		}
+		client = vn007.NewClient(vn007.Endpoint(os.Getenv("IP")), os.Getenv("UNICOM_USER"), os.Getenv("PASSWORD_HASH"))
 	}
+	return client
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	url := fmt.Sprintf("http://%s/cgi-bin/http.cgi", os.Getenv("IP"))
-
-	_, err = sendRequestWithRetry(nil, client, url, monitorPayload, "Monitoring")
+func TestClient_Status(t *testing.T) {
+	_, err := liveClient().Status(context.Background())
 
 	if err != nil {
 		t.Fatalf("Monitoring failed: %s", err)
@@ -45,59 +40,21 @@ func TestSendRequestWithRetry_Monitoring(t *testing.T) {
 
 }
 
-func TestSendRequestWithRetry_Login(t *testing.T) {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
-	loginPayload := LoginPayload{
-		Cmd:           100,
-		Method:        "POST",
-		SessionId:     "",
-		Username:      os.Getenv("UNICOM_USER"),
-		Passwd:        os.Getenv("PASSWORD_HASH"),
-		IsAutoUpgrade: "0",
-		Language:      "EN",
-	}
+func TestClient_Login(t *testing.T) {
+	err := liveClient().Login(context.Background())
 
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	url := fmt.Sprintf("http://%s/cgi-bin/http.cgi", os.Getenv("IP"))
-
-	responseData, err := sendRequestWithRetry(nil, client, url, loginPayload, "Login")
-
-	if err != nil || responseData.SessionId == nil {
+	if err != nil || liveClient().SessionID() == "" {
 		t.Fatalf("Login failed: %s", err)
 	} else {
-		sessionId = responseData.SessionId.(string)
 		t.Logf("Login OK")
 	}
 
 }
 
-func TestSendRequestWithRetry_Reboot(t *testing.T) {
+func TestClient_Reboot(t *testing.T) {
+	err := liveClient().Reboot(context.Background())
 
-	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
-	rebootPayload := RebootPayload{
-		Cmd:        6,
-		RebootType: 1,
-		Method:     "POST",
-		SessionId:  sessionId,
-		Language:   "EN",
-	}
-
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	url := fmt.Sprintf("http://%s/cgi-bin/http.cgi", os.Getenv("IP"))
-
-	responseData, err := sendRequestWithRetry(nil, client, url, rebootPayload, "Reboot")
-
-	if err != nil || !responseData.Success {
 		t.Fatalf("Reboot failed: %s", err)
 	} else {
 		t.Logf("Reboot OK")
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron expression for planned reboots: minute, hour, day of
+// month, month and day of week, e.g. "0 4 * * *" for 04:00 every night.
+// Fields take *, numbers, ranges (1-5), lists (1,15) and steps (*/2); day
+// names and @hourly, @daily and @weekly are understood too. The zero
+// Schedule never fires.
+type Schedule struct {
+	spec             string
+	minute, hour     uint64
+	dom, month, dow  uint64
+	domStar, dowStar bool
+}
+
+var scheduleMacros = map[string]string{
+	"@hourly": "0 * * * *",
+	"@daily":  "0 0 * * *",
+	"@weekly": "0 0 * * 0",
+}
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// IsZero reports whether the schedule is off.
+func (s Schedule) IsZero() bool {
+	return s.spec == ""
+}
+
+func (s Schedule) String() string {
+	if s.IsZero() {
+		return "off"
+	}
+	return s.spec
+}
+
+// Set parses a cron expression; "" and "off" turn the schedule off.
+func (s *Schedule) Set(spec string) error {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		*s = Schedule{}
+		return nil
+	}
+	expr := spec
+	if macro, ok := scheduleMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return fmt.Errorf("want 5 cron fields (minute hour day month weekday), got %q", spec)
+	}
+
+	parsed := Schedule{spec: spec}
+	var err error
+	if parsed.minute, err = cronField(fields[0], 0, 59, nil); err != nil {
+		return fmt.Errorf("minute: %v", err)
+	}
+	if parsed.hour, err = cronField(fields[1], 0, 23, nil); err != nil {
+		return fmt.Errorf("hour: %v", err)
+	}
+	if parsed.dom, err = cronField(fields[2], 1, 31, nil); err != nil {
+		return fmt.Errorf("day of month: %v", err)
+	}
+	if parsed.month, err = cronField(fields[3], 1, 12, nil); err != nil {
+		return fmt.Errorf("month: %v", err)
+	}
+	if parsed.dow, err = cronField(fields[4], 0, 7, dayNames); err != nil {
+		return fmt.Errorf("day of week: %v", err)
+	}
+	// 7 is Sunday too.
+	if parsed.dow&(1<<7) != 0 {
+		parsed.dow |= 1
+	}
+	parsed.domStar = strings.HasPrefix(fields[2], "*")
+	parsed.dowStar = strings.HasPrefix(fields[4], "*")
+	*s = parsed
+	return nil
+}
+
+// cronField parses one field into a bit set of the values it allows.
+func cronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = cronValue(first, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(last, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(text string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(text, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%q is not in %d-%d", text, min, max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t the schedule fires, in t's location,
+// or the zero time if it never does.
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.IsZero() {
+		return time.Time{}
+	}
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron: when both day fields are restricted, either
+// may match.
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Window is a daily stretch of local time, optionally limited to some days
+// of the week. A window that ends before it starts runs past midnight.
+type Window struct {
+	days       uint8 // bit per time.Weekday, 0 for every day
+	start, end int   // minutes after midnight
+}
+
+// Windows are the quiet windows during which the watchdog does not reboot
+// on its own, separated by semicolons, e.g. "mon-fri 09:00-10:00; 22:00-06:00".
+// Days take the same forms as the cron weekday field.
+type Windows []Window
+
+func (w Windows) String() string {
+	if len(w) == 0 {
+		return "off"
+	}
+	parts := make([]string, len(w))
+	for i, win := range w {
+		parts[i] = win.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Set parses a list of windows; "" and "off" mean none.
+func (w *Windows) Set(spec string) error {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		*w = nil
+		return nil
+	}
+	var windows Windows
+	for _, part := range strings.Split(spec, ";") {
+		win, err := parseWindow(strings.TrimSpace(part))
+		if err != nil {
+			return err
+		}
+		windows = append(windows, win)
+	}
+	*w = windows
+	return nil
+}
+
+func parseWindow(text string) (Window, error) {
+	var win Window
+	fields := strings.Fields(text)
+	switch len(fields) {
+	case 1:
+	case 2:
+		days, err := cronField(fields[0], 0, 6, dayNames)
+		if err != nil {
+			return win, fmt.Errorf("quiet window %q: %v", text, err)
+		}
+		win.days = uint8(days)
+		fields = fields[1:]
+	default:
+		return win, fmt.Errorf("quiet window %q: want [days] HH:MM-HH:MM", text)
+	}
+
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return win, fmt.Errorf("quiet window %q: want HH:MM-HH:MM", text)
+	}
+	var err error
+	if win.start, err = clockMinutes(start); err != nil {
+		return win, fmt.Errorf("quiet window %q: %v", text, err)
+	}
+	if win.end, err = clockMinutes(end); err != nil {
+		return win, fmt.Errorf("quiet window %q: %v", text, err)
+	}
+	if win.start == win.end {
+		return win, fmt.Errorf("quiet window %q is empty", text)
+	}
+	return win, nil
+}
+
+func clockMinutes(text string) (int, error) {
+	t, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", text)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w Window) String() string {
+	span := fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+	if w.days == 0 {
+		return span
+	}
+	var days []string
+	for d := 0; d < 7; d++ {
+		if w.days&(1<<uint(d)) != 0 {
+			days = append(days, dayNames[d])
+		}
+	}
+	return strings.Join(days, ",") + " " + span
+}
+
+// until returns when the window around t ends, or the zero time if t is
+// outside it.
+func (w Window) until(t time.Time) time.Time {
+	minute := t.Hour()*60 + t.Minute()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	onDay := func(d time.Weekday) bool { return w.days == 0 || w.days&(1<<uint(d)) != 0 }
+
+	if w.start < w.end {
+		if onDay(t.Weekday()) && minute >= w.start && minute < w.end {
+			return midnight.Add(time.Duration(w.end) * time.Minute)
+		}
+		return time.Time{}
+	}
+	// Past midnight: the evening part belongs to today, the morning part
+	// to the window that started yesterday.
+	if onDay(t.Weekday()) && minute >= w.start {
+		return midnight.AddDate(0, 0, 1).Add(time.Duration(w.end) * time.Minute)
+	}
+	if onDay((t.Weekday()+6)%7) && minute < w.end {
+		return midnight.Add(time.Duration(w.end) * time.Minute)
+	}
+	return time.Time{}
+}
+
+// QuietUntil returns when the quiet window around t ends, or the zero time
+// if t is not in one.
+func (w Windows) QuietUntil(t time.Time) time.Time {
+	var latest time.Time
+	for _, win := range w {
+		if end := win.until(t); end.After(latest) {
+			latest = end
+		}
+	}
+	return latest
+}
+
+// Contains reports whether t falls into a quiet window.
+func (w Windows) Contains(t time.Time) bool {
+	return !w.QuietUntil(t).IsZero()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	// A Tuesday.
+	now := time.Date(2024, 10, 1, 12, 30, 45, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"0 4 * * *", time.Date(2024, 10, 2, 4, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 10, 1, 13, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 10, 1, 12, 45, 0, 0, time.UTC)},
+		{"30 3 * * sat,sun", time.Date(2024, 10, 5, 3, 30, 0, 0, time.UTC)},
+		{"0 2 * * 7", time.Date(2024, 10, 6, 2, 0, 0, 0, time.UTC)},
+		{"0 0 1 1-3 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches.
+		{"0 5 15 * mon", time.Date(2024, 10, 7, 5, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		var s Schedule
+		if err := s.Set(tt.spec); err != nil {
+			t.Errorf("Set(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(now); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"0 4 * *", "60 * * * *", "0 24 * * *", "5-1 * * * *", "*/0 * * * *", "0 0 * * funday"} {
+		var s Schedule
+		if err := s.Set(spec); err == nil {
+			t.Errorf("Set(%q) accepted", spec)
+		}
+	}
+
+	var s Schedule
+	s.Set("0 4 * * *")
+	if err := s.Set("off"); err != nil || !s.IsZero() || !s.Next(time.Now()).IsZero() || s.String() != "off" {
+		t.Errorf("off schedule = %v, %v", s, err)
+	}
+}
+
+func TestWindows_QuietUntil(t *testing.T) {
+	var w Windows
+	if err := w.Set("mon-fri 09:00-12:00; 22:00-06:30"); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "mon,tue,wed,thu,fri 09:00-12:00; 22:00-06:30" {
+		t.Errorf("String = %q", w.String())
+	}
+
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 10, day, hour, min, 0, 0, time.UTC) }
+	tests := []struct {
+		t    time.Time
+		want time.Time
+	}{
+		{at(1, 9, 0), at(1, 12, 0)},   // Tuesday morning
+		{at(1, 12, 0), time.Time{}},   // end is exclusive
+		{at(5, 10, 0), time.Time{}},   // Saturday
+		{at(1, 23, 15), at(2, 6, 30)}, // past midnight
+		{at(2, 5, 59), at(2, 6, 30)},  // the morning after
+		{at(2, 6, 30), time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := w.QuietUntil(tt.t); !got.Equal(tt.want) {
+			t.Errorf("QuietUntil(%s) = %s, want %s", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestWindows_Invalid(t *testing.T) {
+	for _, spec := range []string{"09:00", "9-17", "mon-fri", "xyz 09:00-10:00", "10:00-10:00", "mon fri 09:00-10:00"} {
+		var w Windows
+		if err := w.Set(spec); err == nil {
+			t.Errorf("Set(%q) accepted", spec)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Throughput is the WAN transfer rate in bytes per second: the latest
+// reading, the average since the monitor started and the peak reading.
+type Throughput struct {
+	Up       float64 `json:"up"`
+	Down     float64 `json:"down"`
+	AvgUp    float64 `json:"avg_up"`
+	AvgDown  float64 `json:"avg_down"`
+	PeakUp   float64 `json:"peak_up"`
+	PeakDown float64 `json:"peak_down"`
+}
+
+// rateMeter turns the router's cumulative byte counters into rates.
+type rateMeter struct {
+	last    Sample
+	lastAt  time.Time
+	have    bool
+	up      float64       // bytes sent since the first sample
+	down    float64       // bytes received since the first sample
+	elapsed time.Duration // time covered by up and down
+	rates   Throughput
+}
+
+// add takes a sample read at time at and returns the rates so far. A
+// counter that went backwards was reset by a reboot; what it shows now was
+// transferred since then, within the router's uptime.
+func (r *rateMeter) add(sample Sample, at time.Time) Throughput {
+	defer func() { r.last, r.lastAt, r.have = sample, at, true }()
+	if !r.have {
+		return r.rates
+	}
+	interval := at.Sub(r.lastAt)
+	up, down := sample.TxBytes-r.last.TxBytes, sample.RxBytes-r.last.RxBytes
+	if up < 0 || down < 0 {
+		up, down = sample.TxBytes, sample.RxBytes
+		if sinceBoot := time.Duration(sample.Uptime) * time.Second; sinceBoot > 0 && sinceBoot < interval {
+			interval = sinceBoot
+		}
+	}
+	if interval <= 0 {
+		return r.rates
+	}
+
+	secs := interval.Seconds()
+	r.rates.Up, r.rates.Down = float64(up)/secs, float64(down)/secs
+	r.rates.PeakUp = math.Max(r.rates.PeakUp, r.rates.Up)
+	r.rates.PeakDown = math.Max(r.rates.PeakDown, r.rates.Down)
+	r.up += float64(up)
+	r.down += float64(down)
+	r.elapsed += interval
+	r.rates.AvgUp = r.up / r.elapsed.Seconds()
+	r.rates.AvgDown = r.down / r.elapsed.Seconds()
+	return r.rates
+}
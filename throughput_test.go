@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateMeter(t *testing.T) {
+	var meter rateMeter
+	start := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	if rates := meter.add(Sample{Uptime: 100, TxBytes: 1000, RxBytes: 5000}, start); rates != (Throughput{}) {
+		t.Fatalf("first sample rates = %+v, want none", rates)
+	}
+	rates := meter.add(Sample{Uptime: 102, TxBytes: 3000, RxBytes: 25000}, start.Add(2*time.Second))
+	if rates.Up != 1000 || rates.Down != 10000 || rates.PeakDown != 10000 || rates.AvgDown != 10000 {
+		t.Fatalf("rates = %+v, want 1000 up and 10000 down", rates)
+	}
+	rates = meter.add(Sample{Uptime: 104, TxBytes: 3000, RxBytes: 25000}, start.Add(4*time.Second))
+	if rates.Down != 0 || rates.PeakDown != 10000 || rates.AvgDown != 5000 {
+		t.Fatalf("idle rates = %+v, want the peak kept and the average halved", rates)
+	}
+
+	// A reboot reset the counters 4s ago, during a 60s gap.
+	rates = meter.add(Sample{Uptime: 4, TxBytes: 400, RxBytes: 8000}, start.Add(64*time.Second))
+	if rates.Up != 100 || rates.Down != 2000 {
+		t.Errorf("rates after a reboot = %+v, want the new counts over the uptime", rates)
+	}
+	if rates.AvgDown != 28000.0/8 {
+		t.Errorf("average after a reboot = %v, want %v", rates.AvgDown, 28000.0/8)
+	}
+}
+
+func TestMonitor_SampleCarriesRates(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+
+	step(t, m, clock)
+	router.Advance(1, 4000, 2000)
+	clock.now = clock.now.Add(time.Second)
+	step(t, m, clock)
+
+	rates := sink.samples[len(sink.samples)-1].Rates
+	if rates.Up <= 0 || rates.Down <= rates.Up {
+		t.Errorf("rates = %+v, want traffic in both directions", rates)
+	}
+}
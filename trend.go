@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// trendLength is how many samples each series keeps, about five minutes
+// at the default poll interval.
+const trendLength = 300
+
+// sparkWidth is the number of columns a sparkline takes in the header.
+const sparkWidth = 22
+
+// sparkBlocks are the sparkline levels, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// rsrqRange is the RSRQ span the signal sparklines cover, in dB.
+var rsrqRange = [2]float64{-20, -3}
+
+// Series is a fixed size ring of readings, oldest first. NaN marks a
+// sample without a reading.
+type Series struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func NewSeries(size int) *Series {
+	return &Series{values: make([]float64, size)}
+}
+
+// Add appends v, dropping the oldest reading once the series is full.
+func (s *Series) Add(v float64) {
+	s.values[s.next] = v
+	s.next = (s.next + 1) % len(s.values)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// Values returns the readings, oldest first.
+func (s *Series) Values() []float64 {
+	if !s.full {
+		return append([]float64(nil), s.values[:s.next]...)
+	}
+	return append(append([]float64(nil), s.values[s.next:]...), s.values[:s.next]...)
+}
+
+// Last returns the newest reading, NaN if there is none.
+func (s *Series) Last() float64 {
+	if !s.full && s.next == 0 {
+		return math.NaN()
+	}
+	return s.values[(s.next+len(s.values)-1)%len(s.values)]
+}
+
+// Max returns the largest reading, 0 if there is none.
+func (s *Series) Max() float64 {
+	var max float64
+	for _, v := range s.Values() {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// Sparkline draws values scaled between lo and hi in width columns. Longer
+// series are squeezed by averaging neighbouring readings; columns without a
+// reading stay blank.
+func Sparkline(values []float64, width int, lo, hi float64) string {
+	var b strings.Builder
+	for col := 0; col < width; col++ {
+		var bucket []float64
+		if len(values) <= width {
+			if i := col - (width - len(values)); i >= 0 {
+				bucket = values[i : i+1]
+			}
+		} else {
+			bucket = values[col*len(values)/width : (col+1)*len(values)/width]
+		}
+
+		sum, n := 0.0, 0
+		for _, v := range bucket {
+			if !math.IsNaN(v) {
+				sum += v
+				n++
+			}
+		}
+		if n == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		level := 0
+		if hi > lo {
+			level = int((sum/float64(n) - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+		}
+		level = min(max(level, 0), len(sparkBlocks)-1)
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// Trend is the recent signal and traffic history of one router.
+type Trend struct {
+	RSRQ   *Series
+	RSRQ5G *Series
+	Has5G  *Series // 1 with 5G, 0 without
+	Rate   *Series // WAN bytes per second, both directions
+}
+
+func NewTrend() *Trend {
+	return &Trend{
+		RSRQ:   NewSeries(trendLength),
+		RSRQ5G: NewSeries(trendLength),
+		Has5G:  NewSeries(trendLength),
+		Rate:   NewSeries(trendLength),
+	}
+}
+
+// Add records a sample.
+func (t *Trend) Add(sample Sample) {
+	t.RSRQ.Add(reading(sample.RSRQ, sample.Freq != "NA"))
+	t.RSRQ5G.Add(reading(sample.RSRQ5G, sample.Freq5G != "NA"))
+	t.Has5G.Add(float64(boolValue(sample.Freq5G != "NA")))
+	t.Rate.Add(sample.Rates.Up + sample.Rates.Down)
+}
+
+func reading(v int, ok bool) float64 {
+	if !ok {
+		return math.NaN()
+	}
+	return float64(v)
+}
+
+// View renders the sparklines with the latest reading of each.
+func (t *Trend) View() string {
+	rsrq := func(s *Series) string {
+		return fmt.Sprintf("%s %s", Sparkline(s.Values(), sparkWidth, rsrqRange[0], rsrqRange[1]), formatReading(s.Last(), "%.0f"))
+	}
+	availability := math.NaN()
+	if values := t.Has5G.Values(); len(values) > 0 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		availability = 100 * sum / float64(len(values))
+	}
+	lines := []string{
+		titleStyle.Render("4G ᯤ  ") + rsrq(t.RSRQ),
+		titleStyle.Render("5G ᯤ  ") + rsrq(t.RSRQ5G),
+		titleStyle.Render("5G %  ") + Sparkline(t.Has5G.Values(), sparkWidth, 0, 1) + " " + formatReading(availability, "%.0f%%"),
+		titleStyle.Render("↑↓/s  ") + Sparkline(t.Rate.Values(), sparkWidth, 0, t.Rate.Max()) + " " + formatRate(t.Rate.Last()),
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatReading(v float64, format string) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return fmt.Sprintf(format, v)
+}
+
+// formatRate prints bytes per second in the largest fitting unit.
+func formatRate(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "-"
+	case v >= 1e6:
+		return fmt.Sprintf("%.1fMB", v/1e6)
+	case v >= 1e3:
+		return fmt.Sprintf("%.0fkB", v/1e3)
+	}
+	return fmt.Sprintf("%.0fB", v)
+}
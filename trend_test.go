@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSeries_Ring(t *testing.T) {
+	s := NewSeries(3)
+	if !math.IsNaN(s.Last()) || len(s.Values()) != 0 {
+		t.Fatal("new series not empty")
+	}
+	for v := 1.0; v <= 4; v++ {
+		s.Add(v)
+	}
+	if got := s.Values(); !reflect.DeepEqual(got, []float64{2, 3, 4}) {
+		t.Errorf("Values = %v, want the oldest dropped", got)
+	}
+	if s.Last() != 4 || s.Max() != 4 {
+		t.Errorf("Last = %v, Max = %v", s.Last(), s.Max())
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]float64{0, math.NaN(), 7}, 5, 0, 7); got != "  ▁ █" {
+		t.Errorf("short series = %q, want right aligned with a gap", got)
+	}
+	if got := Sparkline([]float64{0, 0, 7, 7, 10, 10}, 3, 0, 7); got != "▁██" {
+		t.Errorf("squeezed series = %q, want averaged and clamped", got)
+	}
+}
+
+func TestTrend_Add(t *testing.T) {
+	trend := NewTrend()
+	trend.Add(Sample{Freq: "1", Freq5G: "2", RSRQ: -10})
+	trend.Add(Sample{Freq: "1", Freq5G: "NA", RSRQ: -11, Rates: Throughput{Up: 500, Down: 1000}})
+
+	if got := trend.Rate.Values(); !reflect.DeepEqual(got, []float64{0, 1500}) {
+		t.Errorf("rates = %v, want up and down added", got)
+	}
+	if got := trend.Has5G.Values(); !reflect.DeepEqual(got, []float64{1, 0}) {
+		t.Errorf("5G availability = %v", got)
+	}
+	if rsrq5G := trend.RSRQ5G.Values(); !math.IsNaN(rsrq5G[1]) {
+		t.Errorf("5G RSRQ without 5G = %v, want no reading", rsrq5G[1])
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+// Budget actions, taken when 4G usage nears its budget.
+const (
+	budgetNSA  = "nsa"  // force 5G NSA only, so nothing falls back to 4G
+	budgetData = "data" // switch mobile data off altogether
+)
+
+// usageSaveInterval is how often the usage file is updated while traffic
+// flows.
+const usageSaveInterval = time.Minute
+
+// Usage is the WAN traffic of one router in the current day and month,
+// split by the radio in use. Traffic between two polls counts for the radio
+// seen at the second one.
+type Usage struct {
+	Day     string `json:"day"`   // 2006-01-02, local time
+	Month   string `json:"month"` // 2006-01
+	Day4G   int    `json:"day_4g"`
+	Day5G   int    `json:"day_5g"`
+	Month4G int    `json:"month_4g"`
+	Month5G int    `json:"month_5g"`
+
+	// Guard is the budget action in force, empty when none is.
+	Guard string `json:"guard,omitempty"`
+	// RestoreMode is the network mode to go back to when the nsa guard
+	// lifts.
+	RestoreMode vn007.NetworkMode `json:"restore_mode,omitempty"`
+}
+
+// add counts bytes used at t, starting a new day or month as the calendar
+// turns.
+func (u *Usage) add(t time.Time, bytes int, on5G bool) {
+	u.roll(t)
+	if on5G {
+		u.Day5G += bytes
+		u.Month5G += bytes
+	} else {
+		u.Day4G += bytes
+		u.Month4G += bytes
+	}
+}
+
+// roll starts new periods if t is past the current ones.
+func (u *Usage) roll(t time.Time) {
+	if day := t.Format("2006-01-02"); u.Day != day {
+		u.Day, u.Day4G, u.Day5G = day, 0, 0
+	}
+	if month := t.Format("2006-01"); u.Month != month {
+		u.Month, u.Month4G, u.Month5G = month, 0, 0
+	}
+}
+
+// BudgetAction is what the watchdog does when 4G usage nears its budget.
+type BudgetAction string
+
+func (a BudgetAction) String() string { return string(a) }
+
+func (a *BudgetAction) Set(s string) error {
+	switch s {
+	case budgetNSA, budgetData:
+		*a = BudgetAction(s)
+		return nil
+	}
+	return fmt.Errorf("unknown budget action %q, want nsa or data", s)
+}
+
+// budgetReached returns which 4G budget usage has come near, or "" if
+// neither.
+func (p Policy) budgetReached(u Usage) string {
+	near := func(used, budget int) bool {
+		return budget > 0 && used*100 >= budget*p.BudgetThreshold
+	}
+	switch {
+	case near(u.Day4G, p.Budget4GDay):
+		return "daily 4G budget"
+	case near(u.Month4G, p.Budget4GMonth):
+		return "monthly 4G budget"
+	}
+	return ""
+}
+
+// account adds the traffic since the previous sample to the usage. The
+// router's counters restart with it, so a drop counts as new traffic from
+// zero.
+func (m *Monitor) account(sample Sample) {
+	total := sample.RxBytes + sample.TxBytes
+	used := total - m.lastTotal
+	if !m.haveTotal {
+		used = 0
+	} else if used < 0 {
+		used = total
+	}
+	m.lastTotal, m.haveTotal = total, true
+
+	now := m.clock.Now()
+	day := m.usage.Day
+	m.usage.add(now, used, sample.Freq5G != "NA")
+	if m.usage.Day != day || now.Sub(m.usageSaved) >= usageSaveInterval {
+		m.saveUsage()
+	}
+}
+
+// guardBudget applies the budget action once 4G usage nears its budget and
+// lifts it when a new day or month brings usage back under.
+func (m *Monitor) guardBudget(ctx context.Context) {
+	reason := m.policy.budgetReached(m.usage)
+	if (reason != "") == (m.usage.Guard != "") || m.clock.Now().Before(m.guardRetry) {
+		return
+	}
+
+	logger := m.logger()
+	if reason != "" {
+		action := string(m.policy.BudgetAction)
+		logger.Warn(reason+" nearly used, limiting data", "action", action, "MB(4G day)", float32(m.usage.Day4G)*0.000001, "MB(4G month)", float32(m.usage.Month4G)*0.000001)
+		err := m.session.Do(ctx, func(ctx context.Context) error {
+			if action == budgetData {
+				return m.client.SetMobileData(ctx, false)
+			}
+			mode, err := m.client.NetworkMode(ctx)
+			if err != nil {
+				return err
+			}
+			m.usage.RestoreMode = mode
+			return m.client.SetNetworkMode(ctx, vn007.NetworkMode5GNSA)
+		})
+		m.sinks.Event(m.newEvent(EventBudget, reason, err))
+		if err != nil {
+			logger.Error("budget action failed", "action", action, "error", err, "retry", loginRetryDelay)
+			m.guardRetry = m.clock.Now().Add(loginRetryDelay)
+			return
+		}
+		m.usage.Guard = action
+		m.saveUsage()
+		return
+	}
+
+	logger.Info("4G budget renewed, lifting limit", "action", m.usage.Guard)
+	err := m.session.Do(ctx, func(ctx context.Context) error {
+		if m.usage.Guard == budgetData {
+			return m.client.SetMobileData(ctx, true)
+		}
+		mode := m.usage.RestoreMode
+		if mode == "" {
+			mode = vn007.NetworkModeAuto
+		}
+		return m.client.SetNetworkMode(ctx, mode)
+	})
+	m.sinks.Event(m.newEvent(EventBudget, "budget renewed", err))
+	if err != nil {
+		logger.Error("lifting budget action failed", "error", err, "retry", loginRetryDelay)
+		m.guardRetry = m.clock.Now().Add(loginRetryDelay)
+		return
+	}
+	m.usage.Guard, m.usage.RestoreMode = "", ""
+	m.saveUsage()
+}
+
+func (m *Monitor) saveUsage() {
+	m.usageSaved = m.clock.Now()
+	if m.usageStore == nil {
+		return
+	}
+	if err := m.usageStore.Save(m.name, m.usage); err != nil {
+		m.logger().Warn("error saving usage", "error", err)
+	}
+}
+
+func defaultUsagePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "usage.json"
+	}
+	return filepath.Join(dir, "vn007go", "usage.json")
+}
+
+// UsageStore keeps the usage of every router in a JSON file, so budgets
+// hold across restarts.
+type UsageStore struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// OpenUsage returns the usage store at path, creating its directory.
+func OpenUsage(path string) (*UsageStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating usage directory: %v", err)
+	}
+	return &UsageStore{path: path}, nil
+}
+
+// Load returns the usage recorded for router, zero if there is none.
+func (s *UsageStore) Load(router string) (Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	return all[usageKey(router)], err
+}
+
+// Save records the usage of router, replacing the file atomically.
+func (s *UsageStore) Save(router string, u Usage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	all[usageKey(router)] = u
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing usage: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// usageKey names a router in the usage file. The router of a plain .env
+// setup has no profile name and is kept as "default".
+func usageKey(router string) string {
+	if router == "" {
+		return "default"
+	}
+	return router
+}
+
+func (s *UsageStore) read() (map[string]Usage, error) {
+	all := map[string]Usage{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return all, fmt.Errorf("error reading usage: %v", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return map[string]Usage{}, fmt.Errorf("%s: %v", s.path, err)
+	}
+	return all, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+func TestUsage_RollsOver(t *testing.T) {
+	var u Usage
+	day := time.Date(2024, 10, 31, 23, 0, 0, 0, time.UTC)
+	u.add(day, 100, false)
+	u.add(day, 50, true)
+	if u.Day4G != 100 || u.Day5G != 50 || u.Month4G != 100 || u.Month5G != 50 {
+		t.Fatalf("usage = %+v", u)
+	}
+
+	u.add(day.Add(2*time.Hour), 10, false)
+	if u.Day != "2024-11-01" || u.Day4G != 10 || u.Day5G != 0 || u.Month != "2024-11" || u.Month4G != 10 {
+		t.Errorf("usage after midnight = %+v, want new day and month", u)
+	}
+	u.add(day.Add(26*time.Hour), 5, false)
+	if u.Day4G != 5 || u.Month4G != 15 {
+		t.Errorf("usage next day = %+v, want the month kept", u)
+	}
+}
+
+func TestUsageStore_RoundTrip(t *testing.T) {
+	store, err := OpenUsage(filepath.Join(t.TempDir(), "vn007go", "usage.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := store.Load("home"); err != nil || u != (Usage{}) {
+		t.Fatalf("Load from a new store = %+v, %v", u, err)
+	}
+	want := Usage{Day: "2024-10-01", Month: "2024-10", Day4G: 7, Guard: budgetNSA, RestoreMode: vn007.NetworkModeAuto}
+	if err := store.Save("home", want); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("", Usage{Day5G: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := store.Load("home"); err != nil || u != want {
+		t.Errorf("Load = %+v, %v; want %+v", u, err, want)
+	}
+}
+
+func TestMonitor_AccountsTrafficByRadio(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+
+	step(t, m, clock)
+	router.Advance(1, 3000, 1000)
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(1, 200, 0)
+	step(t, m, clock)
+	// The router restarted its counters.
+	router.Set("wan_rx_bytes", "50")
+	router.Set("wan_tx_bytes", "0")
+	step(t, m, clock)
+
+	u := sink.samples[len(sink.samples)-1].Usage
+	if u.Day5G != 4000 || u.Day4G != 250 || u.Month4G != 250 || u.Day != "2024-10-01" {
+		t.Errorf("usage = %+v, want 4000 bytes on 5G and 250 on 4G", u)
+	}
+}
+
+func TestMonitor_BudgetForcesNSA(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.Budget4GDay = 1000
+	m.policy.RecoverBytes = 1 << 30
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(1, 899, 0)
+	step(t, m, clock)
+	if router.NetworkMode() != vn007.NetworkModeAuto {
+		t.Fatal("limited before the threshold")
+	}
+	router.Advance(1, 1, 0)
+	step(t, m, clock)
+	if router.NetworkMode() != vn007.NetworkMode5GNSA || m.usage.Guard != budgetNSA || sink.count(EventBudget) != 1 {
+		t.Fatalf("mode = %q, guard = %q; want 5G NSA only", router.NetworkMode(), m.usage.Guard)
+	}
+	step(t, m, clock)
+	if router.Calls(vn007.CmdNetworkMode) != 2 {
+		t.Errorf("network mode calls = %d, want the action taken once", router.Calls(vn007.CmdNetworkMode))
+	}
+
+	clock.now = clock.now.Add(12 * time.Hour)
+	step(t, m, clock)
+	if router.NetworkMode() != vn007.NetworkModeAuto || m.usage.Guard != "" || sink.count(EventBudget) != 2 {
+		t.Errorf("mode = %q, guard = %q the next day; want auto restored", router.NetworkMode(), m.usage.Guard)
+	}
+}
+
+func TestMonitor_BudgetKeepsNSAChosenByUser(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+	m.policy.Budget4GDay = 1000
+	m.policy.RecoverBytes = 1 << 30
+	session := vn007.NewSession(router.Client())
+	err := session.Do(context.Background(), func(ctx context.Context) error {
+		return session.Client.SetNetworkMode(ctx, vn007.NetworkMode5GNSA)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Close(context.Background())
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(1, 950, 0)
+	step(t, m, clock)
+	if m.usage.Guard != budgetNSA || m.usage.RestoreMode != vn007.NetworkMode5GNSA {
+		t.Fatalf("guard = %q, restore = %q; want NSA remembered", m.usage.Guard, m.usage.RestoreMode)
+	}
+
+	clock.now = clock.now.Add(24 * time.Hour)
+	step(t, m, clock)
+	if router.NetworkMode() != vn007.NetworkMode5GNSA || m.usage.Guard != "" {
+		t.Errorf("mode = %q, guard = %q after renewal; want 5G NSA only kept", router.NetworkMode(), m.usage.Guard)
+	}
+}
+
+func TestMonitor_BudgetSwitchesDataOff(t *testing.T) {
+	m, router, clock, _ := newTestMonitor(t)
+	m.policy.Budget4GMonth = 1000
+	m.policy.BudgetAction = budgetData
+	m.policy.RecoverBytes = 1 << 30
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(1, 950, 0)
+	step(t, m, clock)
+	if router.MobileData() || m.usage.Guard != budgetData {
+		t.Fatalf("data on = %v, guard = %q; want data off", router.MobileData(), m.usage.Guard)
+	}
+
+	clock.now = clock.now.Add(24 * time.Hour)
+	step(t, m, clock)
+	if router.MobileData() {
+		t.Fatal("data back on within the month")
+	}
+	clock.now = time.Date(2024, 11, 1, 0, 0, 1, 0, time.UTC)
+	step(t, m, clock)
+	if !router.MobileData() || m.usage.Guard != "" {
+		t.Errorf("data on = %v, guard = %q in a new month; want data back", router.MobileData(), m.usage.Guard)
+	}
+}
+
+func TestMonitor_LadderKeepsBudgetDataOff(t *testing.T) {
+	m, router, clock, sink := newTestMonitor(t)
+	m.policy.Budget4GMonth = 1000
+	m.policy.BudgetAction = budgetData
+	m.policy.RecoverBytes = 1 << 30
+	m.policy.RecoverySteps.Set("reattach:20s,data:30s,reboot")
+
+	step(t, m, clock)
+	router.Drop5G()
+	router.Advance(1, 950, 0)
+	step(t, m, clock)
+	if router.MobileData() || m.usage.Guard != budgetData {
+		t.Fatalf("data on = %v, guard = %q; want data off", router.MobileData(), m.usage.Guard)
+	}
+
+	router.Advance(recoverSecs, 0, 0)
+	step(t, m, clock) // reattach
+	clock.now = clock.now.Add(20 * time.Second)
+	step(t, m, clock) // data toggle skipped, on to the reboot
+	if router.MobileData() || router.Calls(vn007.CmdMobileData) != 1 {
+		t.Errorf("data on = %v after %d data calls; want the budget kept", router.MobileData(), router.Calls(vn007.CmdMobileData))
+	}
+	if router.Reboots() != 1 || sink.count(EventRecoveryAction) != 1 {
+		t.Errorf("reboots = %d, recovery actions = %d; want the reattach then a reboot", router.Reboots(), sink.count(EventRecoveryAction))
+	}
+}
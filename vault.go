@@ -0,0 +1,300 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"golang.org/x/crypto/argon2"
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+// vaultPassphraseEnv unlocks the vault without a prompt, e.g. for headless
+// mode under systemd.
+const vaultPassphraseEnv = "VN007_VAULT_PASSPHRASE"
+
+// defaultProfileKey stores the credentials of the unnamed router configured
+// through .env alone.
+const defaultProfileKey = "default"
+
+// ErrVaultLocked is returned when the vault passphrase is wrong or the file
+// has been tampered with.
+var ErrVaultLocked = errors.New("wrong vault passphrase or corrupted vault")
+
+// Credentials are the web UI login of one router.
+type Credentials struct {
+	User         string `json:"user"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// vaultFile is the on-disk format. Everything but the KDF parameters is
+// sealed with AES-256-GCM under a key derived from the passphrase with
+// Argon2id.
+type vaultFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory"`
+	Threads    uint8  `json:"threads"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Vault holds router credentials by profile name in an encrypted file.
+type Vault struct {
+	path       string
+	passphrase string
+	entries    map[string]Credentials
+}
+
+func defaultVaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "credentials.vault"
+	}
+	return filepath.Join(dir, "vn007go", "credentials.vault")
+}
+
+// vaultExists reports whether there is a vault file at path.
+func vaultExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// OpenVault decrypts the vault at path. A missing file yields an empty vault
+// that Save will create with passphrase.
+func OpenVault(path, passphrase string) (*Vault, error) {
+	v := &Vault{path: path, passphrase: passphrase, entries: map[string]Credentials{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading vault: %v", err)
+	}
+
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if f.Version != 1 || f.KDF != "argon2id" {
+		return nil, fmt.Errorf("%s: unsupported vault version %d (%s)", path, f.Version, f.KDF)
+	}
+
+	gcm, err := vaultCipher(passphrase, f.Salt, f.Time, f.Memory, f.Threads)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrVaultLocked
+	}
+	if err := json.Unmarshal(plain, &v.entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return v, nil
+}
+
+// Get returns the credentials stored for profile.
+func (v *Vault) Get(profile string) (Credentials, bool) {
+	c, ok := v.entries[vaultKey(profile)]
+	return c, ok
+}
+
+func (v *Vault) Set(profile string, c Credentials) {
+	v.entries[vaultKey(profile)] = c
+}
+
+// Delete removes the credentials of profile.
+func (v *Vault) Delete(profile string) {
+	delete(v.entries, vaultKey(profile))
+}
+
+// Profiles lists the stored profile names, sorted.
+func (v *Vault) Profiles() []string {
+	names := make([]string, 0, len(v.entries))
+	for name := range v.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the vault with a fresh salt and nonce and replaces the file
+// atomically.
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+
+	f := vaultFile{Version: 1, KDF: "argon2id", Time: 3, Memory: 64 * 1024, Threads: 2}
+	f.Salt = make([]byte, 16)
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := vaultCipher(v.passphrase, f.Salt, f.Time, f.Memory, f.Threads)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Ciphertext = gcm.Seal(nil, f.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return fmt.Errorf("error creating vault directory: %v", err)
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("error writing vault: %v", err)
+	}
+	return os.Rename(tmp, v.path)
+}
+
+func vaultCipher(passphrase string, salt []byte, time, memory uint32, threads uint8) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty vault passphrase")
+	}
+	key := argon2.IDKey([]byte(passphrase), salt, time, memory, threads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func vaultKey(profile string) string {
+	if profile == "" {
+		return defaultProfileKey
+	}
+	return profile
+}
+
+// vaultPassphrase returns the passphrase from the environment, or prompts
+// for it on stdin.
+func vaultPassphrase(getenv func(string) string, stdin io.Reader, prompt io.Writer) (string, error) {
+	if p := getenv(vaultPassphraseEnv); p != "" {
+		return p, nil
+	}
+	return readPassword(stdin, prompt, "Vault passphrase: ")
+}
+
+// runCredsCommand implements `vn007go creds set|show|clear`.
+func runCredsCommand(args []string, getenv func(string) string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: vn007go creds set|show|clear [flags]")
+	}
+	sub, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("creds "+sub, flag.ContinueOnError)
+	path := fs.String("vault", defaultVaultPath(), "encrypted credentials file")
+	profile := fs.String("profile", "", "router profile the credentials belong to (default: the router configured in .env; clear: remove the whole vault)")
+	var user *string
+	var hashOnly, reveal *bool
+	switch sub {
+	case "set":
+		user = fs.String("user", "superadmin", "web UI user name")
+		hashOnly = fs.Bool("hash", false, "read a PASSWORD_HASH instead of the plaintext password")
+	case "show":
+		reveal = fs.Bool("reveal", false, "print password hashes instead of masking them")
+	case "clear":
+	default:
+		return fmt.Errorf("unknown creds command %q, want set, show or clear", sub)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Clearing everything needs no passphrase, so a forgotten one can be
+	// recovered from by starting over.
+	if sub == "clear" && *profile == "" {
+		if err := os.Remove(*path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing vault: %v", err)
+		}
+		fmt.Fprintf(stdout, "removed %s\n", *path)
+		return nil
+	}
+	passphrase, err := vaultPassphrase(getenv, stdin, stdout)
+	if err != nil {
+		return err
+	}
+	vault, err := OpenVault(*path, passphrase)
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "set":
+		label := "Router password: "
+		if *hashOnly {
+			label = "Password hash: "
+		}
+		secret, err := readPassword(stdin, stdout, label)
+		if err != nil {
+			return err
+		}
+		if secret == "" {
+			return errors.New("empty password")
+		}
+		hash := secret
+		if !*hashOnly {
+			hash = vn007.HashPassword(secret)
+		}
+		vault.Set(*profile, Credentials{User: *user, PasswordHash: hash})
+		if err := vault.Save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "saved credentials for %s in %s\n", vaultKey(*profile), *path)
+
+	case "show":
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROFILE\tUSER\tPASSWORD HASH")
+		for _, name := range vault.Profiles() {
+			if *profile != "" && name != vaultKey(*profile) {
+				continue
+			}
+			c := vault.entries[name]
+			hash := "********"
+			if *reveal {
+				hash = c.PasswordHash
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, c.User, hash)
+		}
+		w.Flush()
+
+	case "clear":
+		vault.Delete(*profile)
+		if err := vault.Save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "cleared credentials for %s\n", *profile)
+	}
+	return nil
+}
+
+// applyVault replaces the credentials of every router found in the vault.
+func applyVault(vault *Vault, routers []Settings) {
+	for i := range routers {
+		if c, ok := vault.Get(routers[i].Profile); ok {
+			setIfNotEmpty(&routers[i].User, c.User)
+			setIfNotEmpty(&routers[i].PasswordHash, c.PasswordHash)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestVault_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vn007go", "credentials.vault")
+	vault, err := OpenVault(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.Set("", Credentials{User: vn007test.Username, PasswordHash: vn007test.PasswordHash})
+	vault.Set("office", Credentials{User: "admin", PasswordHash: "b2ZmaWNl"})
+	if err := vault.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), vn007test.PasswordHash) || strings.Contains(string(data), "office") {
+		t.Fatalf("vault file holds plaintext:\n%s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("vault mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if _, err := OpenVault(path, "wrong"); !errors.Is(err, ErrVaultLocked) {
+		t.Errorf("OpenVault with wrong passphrase = %v, want ErrVaultLocked", err)
+	}
+
+	vault, err = OpenVault(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := vault.Get(""); !ok || c.PasswordHash != vn007test.PasswordHash {
+		t.Errorf("default credentials = %+v, %v", c, ok)
+	}
+	if got := strings.Join(vault.Profiles(), ","); got != "default,office" {
+		t.Errorf("profiles = %s", got)
+	}
+}
+
+func TestRunCredsCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.vault")
+	env := map[string]string{vaultPassphraseEnv: "s3cret"}
+	getenv := func(k string) string { return env[k] }
+	run := func(stdin string, args ...string) string {
+		t.Helper()
+		var out strings.Builder
+		if err := runCredsCommand(append(args, "--vault", path), getenv, strings.NewReader(stdin), &out); err != nil {
+			t.Fatalf("creds %v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	run(vn007test.Password+"\n", "set", "--profile", "home")
+	run("aGFzaA==\n", "set", "--profile", "office", "--user", "admin", "--hash")
+
+	out := run("", "show")
+	if !strings.Contains(out, "home") || !strings.Contains(out, "admin") || strings.Contains(out, vn007test.PasswordHash) {
+		t.Errorf("show = %q, want masked entries", out)
+	}
+	if out := run("", "show", "--reveal", "--profile", "home"); !strings.Contains(out, vn007test.PasswordHash) || strings.Contains(out, "office") {
+		t.Errorf("show --reveal --profile home = %q", out)
+	}
+
+	routers := []Settings{{Profile: "home", User: "from-env", PasswordHash: "stale"}, {Profile: "garage", PasswordHash: "kept"}}
+	vault, err := OpenVault(path, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyVault(vault, routers)
+	if routers[0].User != vn007test.Username || routers[0].PasswordHash != vn007test.PasswordHash || routers[1].PasswordHash != "kept" {
+		t.Errorf("after applyVault = %+v", routers)
+	}
+
+	run("", "clear", "--profile", "home")
+	if out := run("", "show"); strings.Contains(out, "home") {
+		t.Errorf("home still stored after clear:\n%s", out)
+	}
+	run("", "clear")
+	if vaultExists(path) {
+		t.Error("clear without --profile left the vault file")
+	}
+
+	env[vaultPassphraseEnv] = ""
+	var out2 strings.Builder
+	if err := runCredsCommand([]string{"show", "--vault", path}, getenv, strings.NewReader("\n"), &out2); err != nil {
+		t.Errorf("show on a missing vault = %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package vn007
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReplayDone is returned by a Replayer once the capture holds no more
+// replies for the command asked for.
+var ErrReplayDone = errors.New("replay finished")
+
+// redacted replaces secret values in captures.
+const redacted = "REDACTED"
+
+// secretKeys are the request and reply fields that never reach a capture.
+var secretKeys = []string{"passwd", "sessionId"}
+
+// Exchange is one request/response pair in a capture, stored as a line of
+// JSONL. Request and Response hold the JSON bodies with secrets redacted;
+// a reply that is not JSON is kept verbatim in Body instead.
+type Exchange struct {
+	Time     time.Time       `json:"time"`
+	Router   string          `json:"router,omitempty"`
+	Cmd      int             `json:"cmd"`
+	Request  json.RawMessage `json:"request"`
+	Status   int             `json:"status,omitempty"` // HTTP status, 0 when the request failed
+	Response json.RawMessage `json:"response,omitempty"`
+	Body     string          `json:"body,omitempty"`
+	Error    string          `json:"error,omitempty"` // transport error
+}
+
+// Recorder is an http.RoundTripper that appends every exchange with the
+// router to Out. Each exchange is written with a single Write call, so
+// several recorders may share one *os.File.
+type Recorder struct {
+	Out    io.Writer
+	Router string            // tags each exchange, for captures of several routers
+	Next   http.RoundTripper // nil means http.DefaultTransport
+	Now    func() time.Time  // nil means time.Now
+}
+
+// NewRecorder returns a Recorder for router that wraps next.
+func NewRecorder(out io.Writer, router string, next http.RoundTripper) *Recorder {
+	return &Recorder{Out: out, Router: router, Next: next}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+	ex := Exchange{
+		Time:    now(),
+		Router:  r.Router,
+		Cmd:     commandOf(reqBody),
+		Request: redact(reqBody),
+	}
+
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		ex.Error = err.Error()
+		r.write(ex)
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		ex.Error = err.Error()
+		r.write(ex)
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	ex.Status = resp.StatusCode
+	if json.Valid(respBody) {
+		ex.Response = redact(respBody)
+	} else {
+		ex.Body = string(respBody)
+	}
+	r.write(ex)
+	return resp, nil
+}
+
+// write appends ex to the capture. A capture that cannot be written must
+// not break monitoring, so errors are dropped.
+func (r *Recorder) write(ex Exchange) {
+	line, err := json.Marshal(ex)
+	if err != nil {
+		return
+	}
+	r.Out.Write(append(line, '\n'))
+}
+
+// commandOf returns the cmd of a request body, or 0 if it has none.
+func commandOf(body []byte) int {
+	var req struct {
+		Cmd int `json:"cmd"`
+	}
+	json.Unmarshal(body, &req)
+	return req.Cmd
+}
+
+// redact returns body with every non-empty secret field replaced. Bodies
+// that are not JSON objects are returned as they are.
+func redact(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return json.RawMessage(body)
+	}
+	changed := false
+	for key, v := range fields {
+		for _, secret := range secretKeys {
+			if strings.EqualFold(key, secret) && string(v) != `""` && string(v) != "null" {
+				fields[key] = json.RawMessage(`"` + redacted + `"`)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return json.RawMessage(body)
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return json.RawMessage(body)
+	}
+	return out
+}
+
+// ReadCapture parses a capture written by Recorder.
+func ReadCapture(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var ex Exchange
+		if err := json.Unmarshal(line, &ex); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		exchanges = append(exchanges, ex)
+	}
+	return exchanges, scanner.Err()
+}
+
+// Replayer is an http.RoundTripper that answers from a capture instead of
+// a router. Each command is served its recorded replies in order, whatever
+// the order of the requests; once the replies for a command run out it
+// returns ErrReplayDone and closes Done.
+type Replayer struct {
+	mu      sync.Mutex
+	pending map[int][]Exchange
+	done    chan struct{}
+}
+
+// NewReplayer returns a Replayer serving exchanges.
+func NewReplayer(exchanges []Exchange) *Replayer {
+	r := &Replayer{pending: map[int][]Exchange{}, done: make(chan struct{})}
+	for _, ex := range exchanges {
+		r.pending[ex.Cmd] = append(r.pending[ex.Cmd], ex)
+	}
+	return r
+}
+
+// Done is closed when the replay has run out of replies.
+func (r *Replayer) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	cmd := commandOf(body)
+
+	r.mu.Lock()
+	queue := r.pending[cmd]
+	if len(queue) == 0 {
+		select {
+		case <-r.done:
+		default:
+			close(r.done)
+		}
+		r.mu.Unlock()
+		return nil, fmt.Errorf("cmd %d: %w", cmd, ErrReplayDone)
+	}
+	ex := queue[0]
+	r.pending[cmd] = queue[1:]
+	r.mu.Unlock()
+
+	if ex.Error != "" && ex.Status == 0 {
+		return nil, errors.New(ex.Error)
+	}
+	reply := []byte(ex.Body)
+	if len(ex.Response) > 0 {
+		reply = ex.Response
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", ex.Status, http.StatusText(ex.Status)),
+		StatusCode:    ex.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(reply)),
+		ContentLength: int64(len(reply)),
+		Request:       req,
+	}, nil
+}
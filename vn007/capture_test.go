@@ -0,0 +1,130 @@
+package vn007_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestRecorder_RedactsSecrets(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+
+	var capture bytes.Buffer
+	client := newClient(router)
+	client.HTTPClient.Transport = vn007.NewRecorder(&capture, "home", client.HTTPClient.Transport)
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Status(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(capture.String(), vn007test.PasswordHash) || strings.Contains(capture.String(), client.SessionID()) {
+		t.Errorf("capture leaks secrets:\n%s", capture.String())
+	}
+	exchanges, err := vn007.ReadCapture(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 2 || exchanges[0].Cmd != vn007.CmdLogin || exchanges[1].Cmd != vn007.CmdStatus {
+		t.Fatalf("exchanges = %+v, want login then status", exchanges)
+	}
+	for _, ex := range exchanges {
+		if ex.Router != "home" || ex.Status != 200 || ex.Time.IsZero() || len(ex.Response) == 0 {
+			t.Errorf("exchange = %+v", ex)
+		}
+	}
+	if !strings.Contains(string(exchanges[0].Request), `"username":"superadmin"`) {
+		t.Errorf("login request = %s, want the user name kept", exchanges[0].Request)
+	}
+}
+
+// brokenBody fails partway through a reply.
+type brokenBody struct{ io.Reader }
+
+func (brokenBody) Close() error { return nil }
+
+func (b brokenBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRecorder_ReportsBrokenReply(t *testing.T) {
+	var capture bytes.Buffer
+	recorder := vn007.NewRecorder(&capture, "", roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: brokenBody{strings.NewReader(`{"success":tr`)}}, nil
+	}))
+	req, _ := http.NewRequest(http.MethodPost, "http://router/cgi-bin/http.cgi", strings.NewReader(`{"cmd":133}`))
+	if resp, err := recorder.RoundTrip(req); err == nil || resp != nil {
+		t.Fatalf("RoundTrip = %v, %v; want the read error", resp, err)
+	}
+	exchanges, err := vn007.ReadCapture(&capture)
+	if err != nil || len(exchanges) != 1 || exchanges[0].Error != "connection reset" {
+		t.Errorf("exchanges = %+v, %v; want the error recorded", exchanges, err)
+	}
+}
+
+func TestReplayer_ServesCapture(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	router.Fail(vn007test.FaultMalformedJSON)
+
+	var capture bytes.Buffer
+	client := newClient(router)
+	client.HTTPClient.Transport = vn007.NewRecorder(&capture, "", client.HTTPClient.Transport)
+	if _, err := client.Status(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	router.Drop5G()
+	if _, err := client.Status(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	exchanges, err := vn007.ReadCapture(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 3 || exchanges[0].Body == "" {
+		t.Fatalf("exchanges = %+v, want a malformed reply kept verbatim", exchanges)
+	}
+
+	replayer := vn007.NewReplayer(exchanges)
+	replay := vn007.NewClient("http://replay.invalid/cgi-bin/http.cgi", vn007test.Username, vn007test.PasswordHash)
+	replay.Retry = client.Retry
+	replay.HTTPClient.Transport = replayer
+
+	status, err := replay.Status(context.Background())
+	if err != nil || !status.Has("FREQ_5G") {
+		t.Fatalf("first replayed status = %+v, %v; want 5G after a retry", status, err)
+	}
+	status, err = replay.Status(context.Background())
+	if err != nil || status.Has("FREQ_5G") {
+		t.Fatalf("second replayed status = %+v, %v; want 5G gone", status, err)
+	}
+
+	select {
+	case <-replayer.Done():
+		t.Fatal("replay done before running out")
+	default:
+	}
+	// The client reports retry exhaustion, so only the message survives.
+	if _, err := replay.Status(context.Background()); err == nil || !strings.Contains(err.Error(), vn007.ErrReplayDone.Error()) {
+		t.Errorf("status after the capture = %v, want ErrReplayDone", err)
+	}
+	<-replayer.Done()
+}
@@ -0,0 +1,340 @@
+// Package vn007 drives a VN007/VN007+ router through its /cgi-bin/http.cgi
+// JSON endpoint.
+package vn007
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+var (
+	// ErrAuth is returned when the router rejects the login credentials.
+	ErrAuth = errors.New("authentication failed")
+	// ErrNoSession is returned by commands that need a login first.
+	ErrNoSession = errors.New("not logged in")
+	// ErrSessionExpired is returned when the router no longer accepts the
+	// session of an authenticated command.
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// RetryPolicy controls how often a failed request is retried.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy matches the router's usual recovery time after a hiccup.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	BaseDelay:  1 * time.Second,
+	MaxDelay:   32 * time.Second,
+}
+
+// Backoff returns the delay before retrying the given zero-based attempt.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay * time.Duration(1<<uint(attempt))
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Client talks to a single router and owns its login session.
+type Client struct {
+	URL          string
+	Username     string
+	PasswordHash string
+	HTTPClient   *http.Client
+	Retry        RetryPolicy
+	Logger       *log.Logger
+
+	// ToggleDelay is how long Reattach and ToggleMobileData leave the
+	// modem off.
+	ToggleDelay time.Duration
+
+	// OnRetry, if set, is called before each retry of a failed request.
+	OnRetry func(reqType string, attempt int, err error)
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// Endpoint returns the http.cgi URL for a router reachable at ip.
+func Endpoint(ip string) string {
+	return fmt.Sprintf("http://%s/cgi-bin/http.cgi", ip)
+}
+
+// NewClient returns a client for the endpoint at url using the given
+// web UI credentials.
+func NewClient(url, username, passwordHash string) *Client {
+	return &Client{
+		URL:          url,
+		Username:     username,
+		PasswordHash: passwordHash,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		Retry:        DefaultRetryPolicy,
+		Logger:       log.Default(),
+		ToggleDelay:  DefaultToggleDelay,
+	}
+}
+
+// SessionID returns the current session, or "" when logged out.
+func (c *Client) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+func (c *Client) setSessionID(id string) {
+	c.mu.Lock()
+	c.sessionID = id
+	c.mu.Unlock()
+}
+
+// Status fetches the router's monitoring data (cmd 133).
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	payload := MonitorPayload{
+		Cmd:       CmdStatus,
+		Method:    "GET",
+		Language:  "EN",
+		SessionId: c.SessionID(),
+	}
+
+	var status Status
+	err := c.call(ctx, "Monitoring", payload, func(_ *http.Response, body []byte) error {
+		if err := json.Unmarshal(body, &status); err != nil {
+			return fmt.Errorf("invalid JSON response: %v", err)
+		}
+		if !status.Success {
+			return fmt.Errorf("request failed with success=false")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Login authenticates with the router (cmd 100) and keeps the session for
+// later commands.
+func (c *Client) Login(ctx context.Context) error {
+	payload := LoginPayload{
+		Cmd:           CmdLogin,
+		Method:        "POST",
+		SessionId:     "",
+		Username:      c.Username,
+		Passwd:        c.PasswordHash,
+		IsAutoUpgrade: "0",
+		Language:      "EN",
+	}
+
+	var responseData ResponseData
+	err := c.call(ctx, "Login", payload, func(_ *http.Response, body []byte) error {
+		if err := json.Unmarshal(body, &responseData); err != nil {
+			return fmt.Errorf("invalid JSON response: %v", err)
+		}
+		if responseData.SessionId == "" {
+			return permanent{ErrAuth}
+		}
+		if !responseData.Success {
+			return fmt.Errorf("request failed with success=false")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.setSessionID(responseData.SessionId)
+	return nil
+}
+
+// Reboot restarts the router (cmd 6). The session does not survive the
+// reboot, so the client is logged out afterwards.
+func (c *Client) Reboot(ctx context.Context) error {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return ErrNoSession
+	}
+
+	payload := RebootPayload{
+		Cmd:        CmdReboot,
+		RebootType: 1,
+		Method:     "POST",
+		SessionId:  sessionID,
+		Language:   "EN",
+	}
+
+	err := c.call(ctx, "Reboot", payload, func(resp *http.Response, body []byte) error {
+		if err := checkSession(resp, body); err != nil {
+			return err
+		}
+		// The router may go down before it finishes answering, so a plain
+		// 200 is as much confirmation as we get.
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		var responseData ResponseData
+		return decodeSuccess(body, &responseData)
+	})
+	if errors.Is(err, ErrSessionExpired) {
+		c.setSessionID("")
+	}
+	if err != nil {
+		return err
+	}
+	c.setSessionID("")
+	return nil
+}
+
+// Logout ends the current session so it does not block the web UI.
+func (c *Client) Logout(ctx context.Context) error {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return nil
+	}
+
+	payload := LogoutPayload{
+		Cmd:       CmdLogout,
+		Method:    "POST",
+		SessionId: sessionID,
+		Language:  "EN",
+	}
+
+	var responseData ResponseData
+	err := c.call(ctx, "Logout", payload, func(resp *http.Response, body []byte) error {
+		if err := checkSession(resp, body); err != nil {
+			return err
+		}
+		return decodeSuccess(body, &responseData)
+	})
+	c.setSessionID("")
+	return err
+}
+
+// permanent marks a decode error that retrying cannot fix.
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
+
+// checkSession turns the router's refusal of a session into a permanent
+// ErrSessionExpired. Besides 401 and 403, the firmware may answer 200 with
+// a failed reply whose message names the session or login.
+func checkSession(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return permanent{ErrSessionExpired}
+	}
+	var reply ResponseData
+	if resp.StatusCode == http.StatusOK && json.Unmarshal(body, &reply) == nil && !reply.Success && sessionRefused(reply.Message) {
+		return permanent{ErrSessionExpired}
+	}
+	return nil
+}
+
+func sessionRefused(message string) bool {
+	message = strings.ToLower(message)
+	for _, word := range []string{"session", "auth", "login"} {
+		if strings.Contains(message, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSuccess(body []byte, responseData *ResponseData) error {
+	if err := json.Unmarshal(body, responseData); err != nil {
+		return fmt.Errorf("invalid JSON response: %v", err)
+	}
+	if !responseData.Success {
+		return fmt.Errorf("request failed with success=false")
+	}
+	return nil
+}
+
+// call posts payload to the router and hands the reply to decode, retrying
+// with exponential backoff until decode accepts it, decode returns a
+// permanent error, or the retry budget runs out.
+func (c *Client) call(ctx context.Context, reqType string, payload interface{}, decode func(resp *http.Response, body []byte) error) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON: %v", err)
+	}
+
+	var lastErr error
+
+	for attempt := 0; attempt < c.Retry.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if c.OnRetry != nil {
+				c.OnRetry(reqType, attempt, lastErr)
+			}
+			if err := sleep(ctx, c.Retry.Backoff(attempt-1)); err != nil {
+				return err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", c.URL, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("error creating request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			c.Logger.Error("request failed", "type", reqType, "attempt", attempt+1, "error", err)
+			continue
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			c.Logger.Error("failed to read response", "type", reqType, "attempt", attempt+1, "error", err)
+			continue
+		}
+
+		err = decode(resp, body)
+		var perm permanent
+		if errors.As(err, &perm) {
+			c.Logger.Debug("request rejected", "type", reqType, "attempt", attempt+1, "error", perm.err)
+			return perm.err
+		}
+		if err != nil {
+			lastErr = err
+			c.Logger.Error("request unsuccessful", "type", reqType, "attempt", attempt+1, "error", err)
+			continue
+		}
+
+		c.Logger.Debug("request successful", "type", reqType, "attempt", attempt+1)
+		return nil
+	}
+
+	return fmt.Errorf("max retries (%d) exceeded with error: %v", c.Retry.MaxRetries, lastErr)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,155 @@
+package vn007_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func newClient(router *vn007test.Router) *vn007.Client {
+	client := router.Client()
+	client.Retry = vn007.RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	return client
+}
+
+func TestClient_Status(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+
+	status, err := newClient(router).Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status failed: %s", err)
+	}
+	if status.Freq5G != 627264 {
+		t.Errorf("Freq5G = %v, want 627264", status.Freq5G)
+	}
+}
+
+func TestClient_StatusRetries(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	router.Fail(vn007test.FaultMalformedJSON, vn007test.FaultServerError)
+
+	client := newClient(router)
+	var retries []int
+	client.OnRetry = func(reqType string, attempt int, err error) {
+		if reqType != "Monitoring" || err == nil {
+			t.Errorf("OnRetry(%q, %d, %v)", reqType, attempt, err)
+		}
+		retries = append(retries, attempt)
+	}
+
+	_, err := client.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status failed: %s", err)
+	}
+	if got := router.Calls(vn007.CmdStatus); got != 3 {
+		t.Errorf("status calls = %d, want 3", got)
+	}
+	if len(retries) != 2 {
+		t.Errorf("OnRetry called for attempts %v, want 2 retries", retries)
+	}
+}
+
+func TestClient_StatusGivesUp(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	router.Fail(vn007test.FaultUnsuccessful, vn007test.FaultUnsuccessful, vn007test.FaultUnsuccessful)
+
+	_, err := newClient(router).Status(context.Background())
+	if err == nil {
+		t.Fatal("Status succeeded, want retry error")
+	}
+	if got := router.Calls(vn007.CmdStatus); got != 3 {
+		t.Errorf("status calls = %d, want 3", got)
+	}
+}
+
+func TestClient_LoginRejected(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	router.RejectLogins(true)
+
+	client := newClient(router)
+	err := client.Login(context.Background())
+	if !errors.Is(err, vn007.ErrAuth) {
+		t.Fatalf("Login error = %v, want ErrAuth", err)
+	}
+	if got := router.Calls(vn007.CmdLogin); got != 1 {
+		t.Errorf("login calls = %d, want 1 (no retries on rejection)", got)
+	}
+	if client.SessionID() != "" {
+		t.Errorf("SessionID = %q after rejected login", client.SessionID())
+	}
+}
+
+func TestClient_Reboot(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	router.SetUptime(3600)
+
+	client := newClient(router)
+	if err := client.Reboot(context.Background()); !errors.Is(err, vn007.ErrNoSession) {
+		t.Fatalf("Reboot before login = %v, want ErrNoSession", err)
+	}
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatalf("Login failed: %s", err)
+	}
+	if err := client.Reboot(context.Background()); err != nil {
+		t.Fatalf("Reboot failed: %s", err)
+	}
+	if router.Reboots() != 1 {
+		t.Errorf("reboots = %d, want 1", router.Reboots())
+	}
+	if client.SessionID() != "" {
+		t.Errorf("session kept after reboot")
+	}
+
+	status, err := client.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status failed: %s", err)
+	}
+	if status.Uptime != 0 || !status.Has("uptime") {
+		t.Errorf("uptime = %v after reboot, want 0", status.Uptime)
+	}
+}
+
+func TestClient_Logout(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+
+	client := newClient(router)
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatalf("Login failed: %s", err)
+	}
+	if err := client.Logout(context.Background()); err != nil {
+		t.Fatalf("Logout failed: %s", err)
+	}
+	if router.Sessions() != 0 {
+		t.Errorf("sessions = %d after logout, want 0", router.Sessions())
+	}
+}
+
+func TestClient_RebootExpiredSession(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	client := newClient(router)
+
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	router.ExpireSessions()
+	if err := client.Reboot(context.Background()); !errors.Is(err, vn007.ErrSessionExpired) {
+		t.Fatalf("Reboot with expired session = %v, want ErrSessionExpired", err)
+	}
+	if client.SessionID() != "" {
+		t.Error("expired session kept")
+	}
+	if router.Calls(vn007.CmdReboot) != 1 {
+		t.Errorf("reboot requests = %d, want 1 (no retries on expiry)", router.Calls(vn007.CmdReboot))
+	}
+}
@@ -0,0 +1,259 @@
+package vn007
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NetworkMode selects the radio access technologies the modem may use.
+type NetworkMode string
+
+const (
+	NetworkModeAuto  NetworkMode = "auto"   // 4G and 5G, whichever is available
+	NetworkMode4G    NetworkMode = "lte"    // 4G only
+	NetworkMode5GNSA NetworkMode = "nr_nsa" // 5G NSA only, the mode the README recommends
+	NetworkMode5GSA  NetworkMode = "nr_sa"  // 5G SA only
+)
+
+// NetworkModes lists the modes SetNetworkMode accepts.
+var NetworkModes = []NetworkMode{NetworkModeAuto, NetworkMode4G, NetworkMode5GNSA, NetworkMode5GSA}
+
+// DefaultToggleDelay is how long Reattach and ToggleMobileData leave the
+// modem off before switching it back on.
+const DefaultToggleDelay = 3 * time.Second
+
+// SetAirplaneMode switches the modem radio off (on=true) or back on. The
+// radio detaches from the network while it is off.
+func (c *Client) SetAirplaneMode(ctx context.Context, on bool) error {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return ErrNoSession
+	}
+	return c.command(ctx, "AirplaneMode", AirplanePayload{
+		Cmd:          CmdAirplaneMode,
+		Method:       "POST",
+		SessionId:    sessionID,
+		Language:     "EN",
+		AirplaneMode: onOff(on),
+	})
+}
+
+// SetMobileData enables or disables the WAN data connection.
+func (c *Client) SetMobileData(ctx context.Context, on bool) error {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return ErrNoSession
+	}
+	return c.command(ctx, "MobileData", MobileDataPayload{
+		Cmd:        CmdMobileData,
+		Method:     "POST",
+		SessionId:  sessionID,
+		Language:   "EN",
+		DataSwitch: onOff(on),
+	})
+}
+
+// NetworkMode reads the current network mode.
+func (c *Client) NetworkMode(ctx context.Context) (NetworkMode, error) {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return "", ErrNoSession
+	}
+	var reply NetworkModeReply
+	err := c.query(ctx, "NetworkMode", NetworkModePayload{
+		Cmd:       CmdNetworkMode,
+		Method:    "GET",
+		SessionId: sessionID,
+		Language:  "EN",
+	}, &reply)
+	return NetworkMode(reply.NetworkMode), err
+}
+
+// SetNetworkMode restricts the modem to mode.
+func (c *Client) SetNetworkMode(ctx context.Context, mode NetworkMode) error {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return ErrNoSession
+	}
+	return c.command(ctx, "SetNetworkMode", NetworkModePayload{
+		Cmd:         CmdNetworkMode,
+		Method:      "POST",
+		SessionId:   sessionID,
+		Language:    "EN",
+		NetworkMode: string(mode),
+	})
+}
+
+// BandLock limits the bands the modem may use. An empty list allows every
+// band of that radio.
+type BandLock struct {
+	LTE []int `json:"lte,omitempty"` // E-UTRA bands, e.g. 3 for B3
+	NR  []int `json:"nr,omitempty"`  // NR bands, e.g. 78 for n78
+}
+
+// IsZero reports whether no band is locked.
+func (b BandLock) IsZero() bool {
+	return len(b.LTE) == 0 && len(b.NR) == 0
+}
+
+func (b BandLock) String() string {
+	if b.IsZero() {
+		return "all bands"
+	}
+	var parts []string
+	if len(b.LTE) > 0 {
+		parts = append(parts, "B"+joinBands(b.LTE, ",B"))
+	}
+	if len(b.NR) > 0 {
+		parts = append(parts, "n"+joinBands(b.NR, ",n"))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseBands parses a comma separated band list such as "1,3,41",
+// "B3,B7" or "n78". An empty string is an empty list.
+func ParseBands(s string) ([]int, error) {
+	var bands []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimLeft(strings.TrimSpace(part), "BbNn")
+		if part == "" {
+			continue
+		}
+		band, err := strconv.Atoi(part)
+		if err != nil || band < 1 {
+			return nil, fmt.Errorf("invalid band %q", part)
+		}
+		bands = append(bands, band)
+	}
+	return bands, nil
+}
+
+func joinBands(bands []int, sep string) string {
+	parts := make([]string, len(bands))
+	for i, band := range bands {
+		parts[i] = strconv.Itoa(band)
+	}
+	return strings.Join(parts, sep)
+}
+
+// BandLock reads the current band locks.
+func (c *Client) BandLock(ctx context.Context) (BandLock, error) {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return BandLock{}, ErrNoSession
+	}
+	var reply BandLockReply
+	err := c.query(ctx, "BandLock", BandLockPayload{
+		Cmd:       CmdBandLock,
+		Method:    "GET",
+		SessionId: sessionID,
+		Language:  "EN",
+	}, &reply)
+	if err != nil {
+		return BandLock{}, err
+	}
+	var lock BandLock
+	if lock.LTE, err = ParseBands(reply.LteBand); err != nil {
+		return BandLock{}, fmt.Errorf("LTE bands: %v", err)
+	}
+	if lock.NR, err = ParseBands(reply.NrBand); err != nil {
+		return BandLock{}, fmt.Errorf("NR bands: %v", err)
+	}
+	return lock, nil
+}
+
+// SetBandLock limits the modem to the bands in lock. The zero BandLock
+// unlocks every band.
+func (c *Client) SetBandLock(ctx context.Context, lock BandLock) error {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return ErrNoSession
+	}
+	return c.command(ctx, "SetBandLock", BandLockPayload{
+		Cmd:       CmdBandLock,
+		Method:    "POST",
+		SessionId: sessionID,
+		Language:  "EN",
+		LteBand:   joinBands(lock.LTE, ","),
+		NrBand:    joinBands(lock.NR, ","),
+	})
+}
+
+// Reattach detaches the modem from the network and attaches it again,
+// which makes it search for a cell afresh without a reboot.
+func (c *Client) Reattach(ctx context.Context) error {
+	return c.toggle(ctx, func(ctx context.Context, off bool) error {
+		return c.SetAirplaneMode(ctx, off)
+	})
+}
+
+// ToggleMobileData drops the data connection and brings it back up.
+func (c *Client) ToggleMobileData(ctx context.Context) error {
+	return c.toggle(ctx, func(ctx context.Context, off bool) error {
+		return c.SetMobileData(ctx, !off)
+	})
+}
+
+// toggle switches something off, waits ToggleDelay and switches it on
+// again. It tries to switch back on even if ctx ends while waiting, so the
+// router is not left offline.
+func (c *Client) toggle(ctx context.Context, set func(ctx context.Context, off bool) error) error {
+	if err := set(ctx, true); err != nil {
+		return err
+	}
+	if err := sleep(ctx, c.ToggleDelay); err != nil {
+		ctx = context.WithoutCancel(ctx)
+	}
+	return set(ctx, false)
+}
+
+// command sends an authenticated command that answers {"success":true}.
+func (c *Client) command(ctx context.Context, reqType string, payload interface{}) error {
+	err := c.call(ctx, reqType, payload, func(resp *http.Response, body []byte) error {
+		if err := checkSession(resp, body); err != nil {
+			return err
+		}
+		var responseData ResponseData
+		return decodeSuccess(body, &responseData)
+	})
+	if errors.Is(err, ErrSessionExpired) {
+		c.setSessionID("")
+	}
+	return err
+}
+
+// query sends an authenticated read and decodes its reply, which must
+// carry "success":true, into reply.
+func (c *Client) query(ctx context.Context, reqType string, payload, reply interface{}) error {
+	err := c.call(ctx, reqType, payload, func(resp *http.Response, body []byte) error {
+		if err := checkSession(resp, body); err != nil {
+			return err
+		}
+		var responseData ResponseData
+		if err := decodeSuccess(body, &responseData); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, reply); err != nil {
+			return fmt.Errorf("invalid JSON response: %v", err)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrSessionExpired) {
+		c.setSessionID("")
+	}
+	return err
+}
+
+// onOff encodes a switch as "1" or "0".
+func onOff(on bool) string {
+	if on {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,125 @@
+package vn007_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestClient_Reattach(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	router.Drop5G()
+	router.ReattachRestores5G(true)
+
+	client := newClient(router)
+	if err := client.Reattach(context.Background()); !errors.Is(err, vn007.ErrNoSession) {
+		t.Errorf("Reattach before login = %v, want ErrNoSession", err)
+	}
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Reattach(context.Background()); err != nil {
+		t.Fatalf("Reattach failed: %s", err)
+	}
+	if router.Calls(vn007.CmdAirplaneMode) != 2 || router.AirplaneMode() {
+		t.Errorf("airplane calls = %d, on = %v; want off then on again", router.Calls(vn007.CmdAirplaneMode), router.AirplaneMode())
+	}
+	status, err := client.Status(context.Background())
+	if err != nil || !status.Has("FREQ_5G") {
+		t.Errorf("status after reattach = %+v, %v; want 5G back", status, err)
+	}
+}
+
+func TestClient_ToggleMobileData(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+
+	client := newClient(router)
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetMobileData(context.Background(), false); err != nil || router.MobileData() {
+		t.Fatalf("SetMobileData(false) = %v, data on = %v", err, router.MobileData())
+	}
+	if err := client.ToggleMobileData(context.Background()); err != nil {
+		t.Fatalf("ToggleMobileData failed: %s", err)
+	}
+	if router.Calls(vn007.CmdMobileData) != 3 || !router.MobileData() {
+		t.Errorf("data calls = %d, on = %v; want data back on", router.Calls(vn007.CmdMobileData), router.MobileData())
+	}
+
+	router.ExpireSessions()
+	if err := client.SetMobileData(context.Background(), true); !errors.Is(err, vn007.ErrSessionExpired) || client.SessionID() != "" {
+		t.Errorf("SetMobileData with an expired session = %v, want ErrSessionExpired", err)
+	}
+}
+
+func TestClient_NetworkMode(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+
+	client := newClient(router)
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mode, err := client.NetworkMode(context.Background())
+	if err != nil || mode != vn007.NetworkModeAuto {
+		t.Fatalf("NetworkMode = %q, %v; want auto", mode, err)
+	}
+	if err := client.SetNetworkMode(context.Background(), vn007.NetworkMode5GNSA); err != nil {
+		t.Fatalf("SetNetworkMode failed: %s", err)
+	}
+	if router.NetworkMode() != vn007.NetworkMode5GNSA {
+		t.Errorf("router mode = %q, want %q", router.NetworkMode(), vn007.NetworkMode5GNSA)
+	}
+	if mode, err := client.NetworkMode(context.Background()); err != nil || mode != vn007.NetworkMode5GNSA {
+		t.Errorf("NetworkMode after set = %q, %v", mode, err)
+	}
+}
+
+func TestClient_BandLock(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+
+	client := newClient(router)
+	if _, err := client.BandLock(context.Background()); !errors.Is(err, vn007.ErrNoSession) {
+		t.Errorf("BandLock before login = %v, want ErrNoSession", err)
+	}
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := client.BandLock(context.Background())
+	if err != nil || !lock.IsZero() {
+		t.Fatalf("BandLock = %v, %v; want all bands", lock, err)
+	}
+
+	want := vn007.BandLock{LTE: []int{1, 3}, NR: []int{78}}
+	if err := client.SetBandLock(context.Background(), want); err != nil {
+		t.Fatalf("SetBandLock failed: %s", err)
+	}
+	if lock, err := client.BandLock(context.Background()); err != nil || lock.String() != "B1,B3 n78" {
+		t.Errorf("BandLock after set = %v, %v; want B1,B3 n78", lock, err)
+	}
+	if err := client.SetBandLock(context.Background(), vn007.BandLock{}); err != nil || !router.BandLock().IsZero() {
+		t.Errorf("unlocking = %v, router lock = %v", err, router.BandLock())
+	}
+}
+
+func TestParseBands(t *testing.T) {
+	bands, err := vn007.ParseBands(" B3, n78,41 ")
+	if err != nil || len(bands) != 3 || bands[0] != 3 || bands[1] != 78 || bands[2] != 41 {
+		t.Errorf("ParseBands = %v, %v", bands, err)
+	}
+	if bands, err := vn007.ParseBands(""); err != nil || bands != nil {
+		t.Errorf("ParseBands(\"\") = %v, %v; want none", bands, err)
+	}
+	for _, s := range []string{"x", "n0", "3;7"} {
+		if _, err := vn007.ParseBands(s); err == nil {
+			t.Errorf("ParseBands(%q) accepted", s)
+		}
+	}
+}
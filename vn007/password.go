@@ -0,0 +1,16 @@
+package vn007
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// HashPassword returns the Passwd value the web UI sends at login for a
+// plaintext password. The login page hashes the password with MD5 and
+// base64-encodes the lowercase hex digest, so this matches the value seen
+// in the browser's developer tools.
+func HashPassword(password string) string {
+	sum := md5.Sum([]byte(password))
+	return base64.StdEncoding.EncodeToString([]byte(hex.EncodeToString(sum[:])))
+}
@@ -0,0 +1,18 @@
+package vn007_test
+
+import (
+	"testing"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestHashPassword(t *testing.T) {
+	if got := vn007.HashPassword(vn007test.Password); got != vn007test.PasswordHash {
+		t.Errorf("HashPassword(%q) = %s, want %s", vn007test.Password, got, vn007test.PasswordHash)
+	}
+	// The web UI sends 44 characters: base64 of a 32-digit hex digest.
+	if got := vn007.HashPassword(""); len(got) != 44 {
+		t.Errorf("HashPassword(\"\") = %s, want 44 characters", got)
+	}
+}
@@ -0,0 +1,120 @@
+package vn007
+
+// Command identifiers understood by the router's http.cgi endpoint.
+const (
+	CmdReboot = 6
+	CmdLogin  = 100
+	CmdLogout = 101
+	CmdStatus = 133
+)
+
+// Modem control commands. Unlike the ones above, their numbers and fields
+// have not been confirmed against a capture of a real router, so the
+// watchdog only sends them with its experimental switch on.
+const (
+	CmdAirplaneMode = 116
+	CmdMobileData   = 222
+	CmdNetworkMode  = 283
+	CmdBandLock     = 284
+)
+
+type LoginPayload struct {
+	Cmd           int    `json:"cmd"`
+	Method        string `json:"method"`
+	Language      string `json:"language"`
+	SessionId     string `json:"sessionId"`
+	Username      string `json:"username"`
+	Passwd        string `json:"passwd"`
+	IsAutoUpgrade string `json:"isAutoUpgrade"`
+}
+
+type LogoutPayload struct {
+	Cmd       int    `json:"cmd"`
+	Method    string `json:"method"`
+	SessionId string `json:"sessionId"`
+	Language  string `json:"language"`
+}
+
+type MonitorPayload struct {
+	Cmd       int    `json:"cmd"`
+	Method    string `json:"method"`
+	Language  string `json:"language"`
+	SessionId string `json:"sessionId"`
+}
+
+type RebootPayload struct {
+	Cmd        int    `json:"cmd"`
+	RebootType int    `json:"rebootType"`
+	Method     string `json:"method"`
+	SessionId  string `json:"sessionId"`
+	Language   string `json:"language"`
+}
+
+// AirplanePayload switches the modem radio off ("1") or on ("0").
+type AirplanePayload struct {
+	Cmd          int    `json:"cmd"`
+	Method       string `json:"method"`
+	SessionId    string `json:"sessionId"`
+	Language     string `json:"language"`
+	AirplaneMode string `json:"airplaneMode"`
+}
+
+// MobileDataPayload enables ("1") or disables ("0") the WAN data
+// connection.
+type MobileDataPayload struct {
+	Cmd        int    `json:"cmd"`
+	Method     string `json:"method"`
+	SessionId  string `json:"sessionId"`
+	Language   string `json:"language"`
+	DataSwitch string `json:"dataSwitch"`
+}
+
+// NetworkModePayload reads (GET) or sets (POST) the radio access
+// technologies the modem may use.
+type NetworkModePayload struct {
+	Cmd         int    `json:"cmd"`
+	Method      string `json:"method"`
+	SessionId   string `json:"sessionId"`
+	Language    string `json:"language"`
+	NetworkMode string `json:"networkMode,omitempty"`
+}
+
+// NetworkModeReply is the reply to a network mode read.
+type NetworkModeReply struct {
+	Success     bool   `json:"success"`
+	NetworkMode string `json:"networkMode"`
+}
+
+// BandLockPayload reads (GET) or sets (POST) the band locks. Bands are
+// comma separated numbers; an empty list allows every band.
+type BandLockPayload struct {
+	Cmd       int    `json:"cmd"`
+	Method    string `json:"method"`
+	SessionId string `json:"sessionId"`
+	Language  string `json:"language"`
+	LteBand   string `json:"lteBand"`
+	NrBand    string `json:"nrBand"`
+}
+
+// BandLockReply is the reply to a band lock read.
+type BandLockReply struct {
+	Success bool   `json:"success"`
+	LteBand string `json:"lteBand"`
+	NrBand  string `json:"nrBand"`
+}
+
+type GetInfoPayload struct {
+	Cmd       int    `json:"cmd"`
+	Method    string `json:"method"`
+	SessionId string `json:"sessionId"`
+	Language  string `json:"language"`
+}
+
+// ResponseData is the reply to the login, logout, reboot and modem control
+// commands.
+// Status replies decode into Status.
+type ResponseData struct {
+	Success   bool   `json:"success"`
+	SessionId string `json:"sessionId"`
+	Message   string `json:"message"` // reason given with some failures
+}
@@ -0,0 +1,91 @@
+package vn007
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultSessionTTL is how long an idle session is trusted. The web UI
+// drops idle admin sessions after a few minutes.
+const DefaultSessionTTL = 4 * time.Minute
+
+// Session manages the login of a Client: it logs in once, reuses the
+// session for authenticated commands, logs in again when the session has
+// gone stale or the router reports it expired, and logs out on Close.
+type Session struct {
+	Client *Client
+	TTL    time.Duration
+	Now    func() time.Time // clock for TTL, time.Now by default
+
+	mu       sync.Mutex
+	lastUsed time.Time
+}
+
+func NewSession(client *Client) *Session {
+	return &Session{Client: client, TTL: DefaultSessionTTL, Now: time.Now}
+}
+
+// Login makes sure there is a usable session, logging in only when there is
+// none or it has been idle longer than TTL.
+func (s *Session) Login(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.login(ctx, false)
+}
+
+// login is Login with s.mu held. force discards the current session, which
+// the router has already dropped. A session left idle past TTL may still be
+// open on the router, so it is logged out first.
+func (s *Session) login(ctx context.Context, force bool) error {
+	if !force && s.Client.SessionID() != "" {
+		if s.Now().Sub(s.lastUsed) < s.TTL {
+			return nil
+		}
+		if err := s.Client.Logout(ctx); err != nil {
+			s.Client.Logger.Debug("logging out the idle session failed", "error", err)
+		}
+	}
+	s.Client.setSessionID("")
+	if err := s.Client.Login(ctx); err != nil {
+		return err
+	}
+	s.lastUsed = s.Now()
+	return nil
+}
+
+// Do runs an authenticated command with a valid session. If the router
+// says the session expired, it logs in again and retries once.
+func (s *Session) Do(ctx context.Context, command func(context.Context) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.login(ctx, false); err != nil {
+		return err
+	}
+	err := command(ctx)
+	if errors.Is(err, ErrSessionExpired) {
+		s.Client.Logger.Info("session expired, logging in again")
+		if err := s.login(ctx, true); err != nil {
+			return err
+		}
+		err = command(ctx)
+	}
+	if err == nil {
+		s.lastUsed = s.Now()
+	}
+	return err
+}
+
+// Reboot restarts the router, logging in first if needed.
+func (s *Session) Reboot(ctx context.Context) error {
+	return s.Do(ctx, s.Client.Reboot)
+}
+
+// Close logs out so the session does not lock the web UI.
+func (s *Session) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Client.Logout(ctx)
+}
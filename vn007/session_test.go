@@ -0,0 +1,140 @@
+package vn007_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+	"rpfilomeno.xyz/vn007go/vn007/vn007test"
+)
+
+func TestSession_ReusesLogin(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	session := vn007.NewSession(newClient(router))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := session.Do(ctx, func(context.Context) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := router.Calls(vn007.CmdLogin); n != 1 {
+		t.Errorf("logins = %d, want 1", n)
+	}
+
+	session.TTL = 0
+	if err := session.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := router.Calls(vn007.CmdLogin); n != 2 {
+		t.Errorf("logins after TTL = %d, want 2", n)
+	}
+}
+
+func TestSession_RelogsInWhenExpired(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	session := vn007.NewSession(newClient(router))
+	ctx := context.Background()
+
+	if err := session.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+	router.ExpireSessions()
+	if err := session.Reboot(ctx); err != nil {
+		t.Fatalf("Reboot after expiry = %v", err)
+	}
+	if router.Reboots() != 1 || router.Calls(vn007.CmdLogin) != 2 {
+		t.Errorf("reboots = %d, logins = %d, want 1 and 2", router.Reboots(), router.Calls(vn007.CmdLogin))
+	}
+}
+
+func TestSession_TTLFollowsClock(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	session := vn007.NewSession(newClient(router))
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	session.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := session.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(session.TTL - time.Second)
+	session.Login(ctx)
+	if n := router.Calls(vn007.CmdLogin); n != 1 {
+		t.Fatalf("logins within TTL = %d, want 1", n)
+	}
+	now = now.Add(time.Second)
+	session.Login(ctx)
+	if n := router.Calls(vn007.CmdLogin); n != 2 {
+		t.Errorf("logins once idle for TTL = %d, want 2", n)
+	}
+}
+
+func TestSession_LogsOutIdleSession(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	session := vn007.NewSession(newClient(router))
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	session.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := session.Login(ctx); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(session.TTL)
+	}
+	if router.Sessions() != 1 {
+		t.Errorf("sessions open = %d, want idle ones logged out", router.Sessions())
+	}
+	if err := session.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if router.Sessions() != 0 || router.Calls(vn007.CmdLogout) != 3 {
+		t.Errorf("sessions = %d, logouts = %d after Close; want 0 and 3", router.Sessions(), router.Calls(vn007.CmdLogout))
+	}
+}
+
+func TestSession_RelogsInWhenExpiredWithOK(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	router.RefuseSessionsWithOK(true)
+	session := vn007.NewSession(newClient(router))
+	ctx := context.Background()
+
+	if err := session.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+	router.ExpireSessions()
+	if err := session.Do(ctx, session.Client.ToggleMobileData); err != nil {
+		t.Fatalf("ToggleMobileData after expiry = %v", err)
+	}
+	if router.Calls(vn007.CmdLogin) != 2 || !router.MobileData() {
+		t.Errorf("logins = %d, want a new login after the 200 refusal", router.Calls(vn007.CmdLogin))
+	}
+
+	router.ExpireSessions()
+	if err := session.Reboot(ctx); err != nil || router.Reboots() != 1 {
+		t.Errorf("Reboot after expiry = %v, reboots = %d; want a reboot on a new session", err, router.Reboots())
+	}
+}
+
+func TestSession_CloseLogsOut(t *testing.T) {
+	router := vn007test.NewRouter()
+	defer router.Close()
+	session := vn007.NewSession(newClient(router))
+
+	if err := session.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if router.Sessions() != 0 || session.Client.SessionID() != "" {
+		t.Errorf("session left open after Close")
+	}
+}
@@ -0,0 +1,199 @@
+package vn007
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Status is the validated reply to the status command (cmd 133). The
+// firmware encodes numbers as strings, but plain JSON numbers are accepted
+// too. Keys for the NR cell carry a _5G suffix, as FREQ_5G and RSRQ_5G do.
+// Fields that are absent are listed in Missing and fields that could not be
+// parsed in Invalid; both keep their zero value.
+type Status struct {
+	Success bool
+
+	Uptime  int // seconds since boot
+	RxBytes int // WAN bytes received since boot
+	TxBytes int // WAN bytes sent since boot
+	Freq    int // LTE channel (EARFCN), missing without a data connection
+	Freq5G  int // NR channel (NR-ARFCN), missing without 5G
+	RSRQ    int // LTE reference signal received quality, dB
+	RSRQ5G  int // NR reference signal received quality, dB
+
+	RSRP        int    // LTE reference signal received power, dBm
+	RSRP5G      int    // NR reference signal received power, dBm
+	SINR        int    // LTE signal to interference plus noise ratio, dB
+	SINR5G      int    // NR signal to interference plus noise ratio, dB
+	RSSI        int    // LTE received signal strength, dBm
+	PCI         int    // LTE physical cell ID
+	PCI5G       int    // NR physical cell ID
+	CellID      string // LTE cell identity as reported, usually hex
+	CellID5G    string // NR cell identity as reported
+	Band        string // LTE band, e.g. "B3"
+	Band5G      string // NR band, e.g. "n78"
+	Bandwidth   string // LTE channel bandwidth, e.g. "20MHz"
+	Bandwidth5G string // NR channel bandwidth, e.g. "100MHz"
+	Operator    string // network operator name
+	WanIPv4     string
+	WanIPv6     string
+	Temperature int // modem temperature, °C
+
+	Missing []string
+	Invalid []string
+	Raw     json.RawMessage // the reply as received
+}
+
+// statusField binds a JSON key of the reply to the Status field it fills.
+type statusField struct {
+	key string
+	dst interface{} // *int or *string
+}
+
+func (s *Status) fields() []statusField {
+	return []statusField{
+		{"uptime", &s.Uptime},
+		{"wan_rx_bytes", &s.RxBytes},
+		{"wan_tx_bytes", &s.TxBytes},
+		{"FREQ", &s.Freq},
+		{"FREQ_5G", &s.Freq5G},
+		{"RSRQ", &s.RSRQ},
+		{"RSRQ_5G", &s.RSRQ5G},
+		{"RSRP", &s.RSRP},
+		{"RSRP_5G", &s.RSRP5G},
+		{"SINR", &s.SINR},
+		{"SINR_5G", &s.SINR5G},
+		{"RSSI", &s.RSSI},
+		{"PCI", &s.PCI},
+		{"PCI_5G", &s.PCI5G},
+		{"CELL_ID", &s.CellID},
+		{"CELL_ID_5G", &s.CellID5G},
+		{"BAND", &s.Band},
+		{"BAND_5G", &s.Band5G},
+		{"BANDWIDTH", &s.Bandwidth},
+		{"BANDWIDTH_5G", &s.Bandwidth5G},
+		{"network_operator", &s.Operator},
+		{"wan_ipv4", &s.WanIPv4},
+		{"wan_ipv6", &s.WanIPv6},
+		{"temperature", &s.Temperature},
+	}
+}
+
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*s = Status{Raw: append(json.RawMessage(nil), data...)}
+	if v, ok := raw["success"]; ok {
+		if err := json.Unmarshal(v, &s.Success); err != nil {
+			return fmt.Errorf("success: %v", err)
+		}
+	}
+
+	for _, f := range s.fields() {
+		v, ok := raw[f.key]
+		if !ok || string(v) == "null" {
+			s.Missing = append(s.Missing, f.key)
+			continue
+		}
+		var err error
+		switch dst := f.dst.(type) {
+		case *int:
+			*dst, err = parseInt(v)
+		case *string:
+			*dst, err = parseString(v)
+		}
+		if err != nil {
+			s.Invalid = append(s.Invalid, f.key)
+		}
+	}
+	return nil
+}
+
+// Unknown lists the keys of the reply that Status does not decode, sorted,
+// so new firmware fields can be spotted.
+func (s *Status) Unknown() []string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(s.Raw, &raw); err != nil {
+		return nil
+	}
+	delete(raw, "success")
+	for _, f := range s.fields() {
+		delete(raw, f.key)
+	}
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Has reports whether the reply carried a valid value for the JSON key.
+func (s *Status) Has(key string) bool {
+	for _, k := range s.Missing {
+		if k == key {
+			return false
+		}
+	}
+	for _, k := range s.Invalid {
+		if k == key {
+			return false
+		}
+	}
+	return true
+}
+
+// Require returns an error naming every key in keys that the reply lacks
+// or could not parse.
+func (s *Status) Require(keys ...string) error {
+	var bad []string
+	for _, k := range keys {
+		if !s.Has(k) {
+			bad = append(bad, k)
+		}
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+	sort.Strings(bad)
+	return fmt.Errorf("status reply lacks %s", strings.Join(bad, ", "))
+}
+
+// parseInt accepts 42, "42" and " 42 ". Fractional numbers are truncated.
+func parseInt(v json.RawMessage) (int, error) {
+	var n json.Number
+	if err := json.Unmarshal(v, &n); err != nil {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return 0, err
+		}
+		n = json.Number(strings.TrimSpace(s))
+	}
+	if i, err := strconv.Atoi(string(n)); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(f), nil
+}
+
+// parseString accepts strings and numbers, the latter in their JSON form.
+func parseString(v json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(v, &n); err != nil {
+		return "", err
+	}
+	return string(n), nil
+}
@@ -0,0 +1,82 @@
+package vn007_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+func TestStatus_UnmarshalStringsAndNumbers(t *testing.T) {
+	body := `{"success":true,"uptime":"605","wan_rx_bytes":2000,"wan_tx_bytes":" 1000 ","FREQ":1850.0,"RSRQ":"-10","RSRQ_5G":-11,"extra":"kept"}`
+	var status vn007.Status
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.Success || status.Uptime != 605 || status.RxBytes != 2000 || status.TxBytes != 1000 ||
+		status.Freq != 1850 || status.RSRQ != -10 || status.RSRQ5G != -11 {
+		t.Errorf("status = %+v", status)
+	}
+	if len(status.Missing) == 0 || status.Missing[0] != "FREQ_5G" || status.Has("FREQ_5G") || !status.Has("FREQ") {
+		t.Errorf("Missing = %v, want FREQ_5G first", status.Missing)
+	}
+	if string(status.Raw) != body {
+		t.Errorf("Raw = %s, want the reply unchanged", status.Raw)
+	}
+}
+
+func TestStatus_FullPayload(t *testing.T) {
+	body := `{"success":true,"uptime":"1","wan_rx_bytes":"0","wan_tx_bytes":"0",
+		"RSRP":"-95","RSRP_5G":"-88","SINR":"12","SINR_5G":18.5,"RSSI":"-65",
+		"PCI":"301","PCI_5G":"512","CELL_ID":"0A1B2C3","CELL_ID_5G":123456789,
+		"BAND":"B3","BAND_5G":"n78","BANDWIDTH":"20MHz","BANDWIDTH_5G":"100MHz",
+		"network_operator":"CHN-UNICOM","wan_ipv4":"10.64.12.34","wan_ipv6":"2408:8456::1",
+		"temperature":"46","lan_mac":"00:11:22:33:44:55"}`
+	var status vn007.Status
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	want := vn007.Status{
+		RSRP: -95, RSRP5G: -88, SINR: 12, SINR5G: 18, RSSI: -65, PCI: 301, PCI5G: 512,
+		CellID: "0A1B2C3", CellID5G: "123456789", Band: "B3", Band5G: "n78",
+		Bandwidth: "20MHz", Bandwidth5G: "100MHz", Operator: "CHN-UNICOM",
+		WanIPv4: "10.64.12.34", WanIPv6: "2408:8456::1", Temperature: 46,
+	}
+	got := status
+	got.Success, got.Uptime, got.Missing, got.Invalid, got.Raw = false, 0, nil, nil, nil
+	got.Freq, got.Freq5G, got.RSRQ, got.RSRQ5G = 0, 0, 0, 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("status = %+v, want %+v", got, want)
+	}
+	if len(status.Invalid) != 0 {
+		t.Errorf("Invalid = %v", status.Invalid)
+	}
+	if unknown := status.Unknown(); strings.Join(unknown, ",") != "lan_mac" {
+		t.Errorf("Unknown = %v, want lan_mac", unknown)
+	}
+}
+
+func TestStatus_ReportsBadFields(t *testing.T) {
+	var status vn007.Status
+	if err := json.Unmarshal([]byte(`{"success":true,"uptime":"soon","wan_rx_bytes":null,"FREQ":{"x":1}}`), &status); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(status.Invalid, ",") != "uptime,FREQ" {
+		t.Errorf("Invalid = %v, want uptime,FREQ", status.Invalid)
+	}
+	err := status.Require("uptime", "wan_rx_bytes", "wan_tx_bytes")
+	if err == nil || err.Error() != "status reply lacks uptime, wan_rx_bytes, wan_tx_bytes" {
+		t.Errorf("Require = %v", err)
+	}
+	if err := status.Require("RSRQ_5G"); err == nil {
+		t.Error("Require accepted a missing field")
+	}
+
+	if err := json.Unmarshal([]byte(`["not","an","object"]`), &status); err == nil {
+		t.Error("decoded a JSON array")
+	}
+}
@@ -0,0 +1,409 @@
+// Package vn007test provides an in-process fake of the VN007 http.cgi
+// endpoint, for exercising the client and the monitor without hardware.
+package vn007test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"rpfilomeno.xyz/vn007go/vn007"
+)
+
+// Credentials accepted by a fresh Router. PasswordHash is
+// vn007.HashPassword(Password).
+const (
+	Username     = "superadmin"
+	Password     = "supersecret"
+	PasswordHash = "OWE2MTgyNDhiNjRkYjYyZDE1YjMwMGEwN2IwMDU4MGI="
+)
+
+// Fault is a scripted failure served instead of a normal reply.
+type Fault int
+
+const (
+	// FaultMalformedJSON answers 200 with a body that is not JSON.
+	FaultMalformedJSON Fault = iota
+	// FaultUnsuccessful answers with {"success":false}.
+	FaultUnsuccessful
+	// FaultServerError answers 500 with an empty body.
+	FaultServerError
+)
+
+// Router is a scriptable fake of /cgi-bin/http.cgi. It understands the
+// status (133), login (100), logout (101) and reboot (6) commands.
+type Router struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	status        map[string]interface{}
+	faults        []Fault
+	rejectLogins  bool
+	rebootRestore bool
+	toggleRestore map[int]bool // modem controls that bring FREQ_5G back
+	airplane      bool
+	dataOff       bool
+	networkMode   string
+	lteBand       string // band locks as sent, comma separated
+	nrBand        string
+	sessions      map[string]bool
+	expiredOK     bool // refuse stale sessions with 200 rather than 401
+	nextSession   int
+	calls         map[int]int
+	reboots       int
+}
+
+// NewRouter starts a fake router with 4G and 5G both connected. Callers
+// should Close it when finished.
+func NewRouter() *Router {
+	r := &Router{
+		status: map[string]interface{}{
+			"FREQ":         "1850",
+			"FREQ_5G":      "627264",
+			"RSRQ":         "-10",
+			"RSRQ_5G":      "-11",
+			"uptime":       "600",
+			"wan_rx_bytes": "0",
+			"wan_tx_bytes": "0",
+
+			"RSRP":             "-95",
+			"RSRP_5G":          "-88",
+			"SINR":             "12",
+			"SINR_5G":          "18",
+			"RSSI":             "-65",
+			"PCI":              "301",
+			"PCI_5G":           "512",
+			"CELL_ID":          "0A1B2C3",
+			"CELL_ID_5G":       "1F2E3D4C5",
+			"BAND":             "B3",
+			"BAND_5G":          "n78",
+			"BANDWIDTH":        "20MHz",
+			"BANDWIDTH_5G":     "100MHz",
+			"network_operator": "CHN-UNICOM",
+			"wan_ipv4":         "10.64.12.34",
+			"wan_ipv6":         "2408:8456::1",
+			"temperature":      "46",
+		},
+		rebootRestore: true,
+		toggleRestore: map[int]bool{},
+		networkMode:   string(vn007.NetworkModeAuto),
+		sessions:      map[string]bool{},
+		calls:         map[int]int{},
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	return r
+}
+
+// Endpoint returns the http.cgi URL to hand to vn007.NewClient.
+func (r *Router) Endpoint() string {
+	return r.URL + "/cgi-bin/http.cgi"
+}
+
+// Client returns a client for this router with the accepted credentials.
+// Modem toggles do not wait, since the fake switches instantly.
+func (r *Router) Client() *vn007.Client {
+	client := vn007.NewClient(r.Endpoint(), Username, PasswordHash)
+	client.ToggleDelay = 0
+	return client
+}
+
+// Set overrides a raw field of the status reply. A nil value removes the
+// field.
+func (r *Router) Set(field string, value interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if value == nil {
+		delete(r.status, field)
+		return
+	}
+	r.status[field] = value
+}
+
+// Drop5G removes FREQ_5G from the status reply, as the router does when
+// it falls back to 4G.
+func (r *Router) Drop5G() {
+	r.Set("FREQ_5G", nil)
+}
+
+// Restore5G puts FREQ_5G back into the status reply.
+func (r *Router) Restore5G() {
+	r.Set("FREQ_5G", "627264")
+}
+
+// SetUptime sets the reported uptime in seconds.
+func (r *Router) SetUptime(seconds int) {
+	r.Set("uptime", strconv.Itoa(seconds))
+}
+
+// Advance moves uptime forward and adds traffic to the WAN counters. No
+// traffic flows while mobile data is off.
+func (r *Router) Advance(seconds, rxBytes, txBytes int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dataOff {
+		rxBytes, txBytes = 0, 0
+	}
+	r.status["uptime"] = addString(r.status["uptime"], seconds)
+	r.status["wan_rx_bytes"] = addString(r.status["wan_rx_bytes"], rxBytes)
+	r.status["wan_tx_bytes"] = addString(r.status["wan_tx_bytes"], txBytes)
+}
+
+// RejectLogins makes the router refuse (or accept again) all logins.
+func (r *Router) RejectLogins(reject bool) {
+	r.mu.Lock()
+	r.rejectLogins = reject
+	r.mu.Unlock()
+}
+
+// RebootRestores5G controls whether a reboot brings FREQ_5G back. It does
+// by default; turn it off to simulate a tower outage.
+func (r *Router) RebootRestores5G(restore bool) {
+	r.mu.Lock()
+	r.rebootRestore = restore
+	r.mu.Unlock()
+}
+
+// ReattachRestores5G controls whether switching airplane mode off brings
+// FREQ_5G back. It does not by default.
+func (r *Router) ReattachRestores5G(restore bool) {
+	r.mu.Lock()
+	r.toggleRestore[vn007.CmdAirplaneMode] = restore
+	r.mu.Unlock()
+}
+
+// DataToggleRestores5G controls whether enabling mobile data brings FREQ_5G
+// back. It does not by default.
+func (r *Router) DataToggleRestores5G(restore bool) {
+	r.mu.Lock()
+	r.toggleRestore[vn007.CmdMobileData] = restore
+	r.mu.Unlock()
+}
+
+// AirplaneMode reports whether the modem radio is switched off.
+func (r *Router) AirplaneMode() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.airplane
+}
+
+// MobileData reports whether the data connection is enabled.
+func (r *Router) MobileData() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.dataOff
+}
+
+// NetworkMode returns the network mode last set through the API.
+func (r *Router) NetworkMode() vn007.NetworkMode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return vn007.NetworkMode(r.networkMode)
+}
+
+// BandLock returns the band locks last set through the API.
+func (r *Router) BandLock() vn007.BandLock {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lte, _ := vn007.ParseBands(r.lteBand)
+	nr, _ := vn007.ParseBands(r.nrBand)
+	return vn007.BandLock{LTE: lte, NR: nr}
+}
+
+// Fail queues faults to serve, one per request, before normal replies resume.
+func (r *Router) Fail(faults ...Fault) {
+	r.mu.Lock()
+	r.faults = append(r.faults, faults...)
+	r.mu.Unlock()
+}
+
+// Calls returns how many requests for cmd the router has received.
+func (r *Router) Calls(cmd int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[cmd]
+}
+
+// Reboots returns how many reboots the router has performed.
+func (r *Router) Reboots() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reboots
+}
+
+// ExpireSessions forgets every open session, as the router does after an
+// idle timeout.
+func (r *Router) ExpireSessions() {
+	r.mu.Lock()
+	r.sessions = map[string]bool{}
+	r.mu.Unlock()
+}
+
+// RefuseSessionsWithOK makes commands on an unknown session answer 200
+// with a failed reply naming the session, as some firmware does, instead
+// of 401.
+func (r *Router) RefuseSessionsWithOK(ok bool) {
+	r.mu.Lock()
+	r.expiredOK = ok
+	r.mu.Unlock()
+}
+
+// Sessions returns how many sessions are currently open.
+func (r *Router) Sessions() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessions)
+}
+
+type request struct {
+	Cmd        int    `json:"cmd"`
+	SessionId  string `json:"sessionId"`
+	Username   string `json:"username"`
+	Passwd     string `json:"passwd"`
+	RebootType int    `json:"rebootType"`
+
+	AirplaneMode string `json:"airplaneMode"`
+	DataSwitch   string `json:"dataSwitch"`
+	Method       string `json:"method"`
+	NetworkMode  string `json:"networkMode"`
+	LteBand      string `json:"lteBand"`
+	NrBand       string `json:"nrBand"`
+}
+
+func (r *Router) serve(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost || req.URL.Path != "/cgi-bin/http.cgi" {
+		http.NotFound(w, req)
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var in request
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[in.Cmd]++
+
+	if len(r.faults) > 0 {
+		fault := r.faults[0]
+		r.faults = r.faults[1:]
+		switch fault {
+		case FaultMalformedJSON:
+			io.WriteString(w, `{"success":tru`)
+		case FaultUnsuccessful:
+			io.WriteString(w, `{"success":false}`)
+		case FaultServerError:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	switch in.Cmd {
+	case vn007.CmdStatus:
+		out := map[string]interface{}{"success": true}
+		for k, v := range r.status {
+			out[k] = v
+		}
+		writeJSON(w, out)
+
+	case vn007.CmdLogin:
+		if r.rejectLogins || in.Username != Username || in.Passwd != PasswordHash {
+			writeJSON(w, map[string]interface{}{"success": false})
+			return
+		}
+		r.nextSession++
+		id := fmt.Sprintf("%064x", r.nextSession)
+		r.sessions[id] = true
+		writeJSON(w, map[string]interface{}{"success": true, "sessionId": id})
+
+	case vn007.CmdLogout:
+		delete(r.sessions, in.SessionId)
+		writeJSON(w, map[string]interface{}{"success": true})
+
+	case vn007.CmdReboot:
+		if !r.sessions[in.SessionId] {
+			r.refuseSession(w)
+			return
+		}
+		r.reboots++
+		r.sessions = map[string]bool{}
+		r.status["uptime"] = "0"
+		r.status["wan_rx_bytes"] = "0"
+		r.status["wan_tx_bytes"] = "0"
+		if r.rebootRestore {
+			r.status["FREQ_5G"] = "627264"
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case vn007.CmdAirplaneMode, vn007.CmdMobileData:
+		if !r.sessions[in.SessionId] {
+			r.refuseSession(w)
+			return
+		}
+		var off bool
+		if in.Cmd == vn007.CmdAirplaneMode {
+			r.airplane = in.AirplaneMode == "1"
+			off = r.airplane
+		} else {
+			r.dataOff = in.DataSwitch == "0"
+			off = r.dataOff
+		}
+		if !off && r.toggleRestore[in.Cmd] {
+			r.status["FREQ_5G"] = "627264"
+		}
+		writeJSON(w, map[string]interface{}{"success": true})
+
+	case vn007.CmdNetworkMode:
+		if !r.sessions[in.SessionId] {
+			r.refuseSession(w)
+			return
+		}
+		if in.Method == "POST" {
+			r.networkMode = in.NetworkMode
+		}
+		writeJSON(w, map[string]interface{}{"success": true, "networkMode": r.networkMode})
+
+	case vn007.CmdBandLock:
+		if !r.sessions[in.SessionId] {
+			r.refuseSession(w)
+			return
+		}
+		if in.Method == "POST" {
+			r.lteBand, r.nrBand = in.LteBand, in.NrBand
+		}
+		writeJSON(w, map[string]interface{}{"success": true, "lteBand": r.lteBand, "nrBand": r.nrBand})
+
+	default:
+		writeJSON(w, map[string]interface{}{"success": false})
+	}
+}
+
+func (r *Router) refuseSession(w http.ResponseWriter) {
+	if r.expiredOK {
+		writeJSON(w, map[string]interface{}{"success": false, "message": "session timeout"})
+		return
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	writeJSON(w, map[string]interface{}{"success": false})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func addString(v interface{}, n int) string {
+	s, _ := v.(string)
+	cur, _ := strconv.Atoi(s)
+	return strconv.Itoa(cur + n)
+}